@@ -0,0 +1,89 @@
+// Package basebranch reads files from the base branch of a pull request, instead of the checked out PR head.
+package basebranch
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/runner"
+)
+
+// Reader reads files of a branch of the origin remote, using the git repository in workDir.
+type Reader struct {
+	runner  runner.Runner
+	raw     runner.Runner
+	workDir string
+}
+
+// New returns a Reader.
+func New(r runner.Runner, workDir string) Reader {
+	return Reader{runner: r, raw: r, workDir: workDir}
+}
+
+// WithRawRunner returns a Reader, which reads the file contents with the given Runner.
+// The Runner has to pass the output through unchanged: not redacting it, nor retrying the command into the same output.
+func (r Reader) WithRawRunner(raw runner.Runner) Reader {
+	r.raw = raw
+	return r
+}
+
+// Fetch fetches the given branch from origin. The fetch is shallow only if the clone is already shallow,
+// as it would make a full clone shallow, and Danger's git plugin needs the merge base of the branches.
+func (r Reader) Fetch(branch string) error {
+	args := []string{"fetch"}
+	if r.isShallow() {
+		args = append(args, "--depth=1")
+	}
+	cmd := r.command("git", append(args, "origin", "refs/heads/"+branch)...)
+	log.Printf("$ %s", cmd)
+	if err := r.runner.Run(cmd); err != nil {
+		return fmt.Errorf("failed to fetch %s: %s", branch, err)
+	}
+	return nil
+}
+
+// isShallow returns whether the repository in workDir is a shallow clone.
+func (r Reader) isShallow() bool {
+	out, err := r.runner.Output(r.command("git", "rev-parse", "--is-shallow-repository"))
+	return err == nil && out == "true"
+}
+
+// Exists returns whether the file at pth (relative to workDir) exists in the last fetched branch.
+func (r Reader) Exists(pth string) bool {
+	rel, err := filepath.Rel(r.workDir, pth)
+	if err != nil {
+		return false
+	}
+	return r.runner.Run(r.command("git", "cat-file", "-e", "FETCH_HEAD:./"+filepath.ToSlash(rel))) == nil
+}
+
+// CopyFile writes the content of the file at pth (relative to workDir) in the last fetched branch to dst.
+func (r Reader) CopyFile(pth, dst string) error {
+	rel, err := filepath.Rel(r.workDir, pth)
+	if err != nil {
+		return err
+	}
+
+	var content bytes.Buffer
+	cmd := r.command("git", "show", "FETCH_HEAD:./"+filepath.ToSlash(rel))
+	cmd.Stdout = &content
+	log.Printf("$ %s", cmd)
+	if err := r.raw.Run(cmd); err != nil {
+		return fmt.Errorf("failed to read %s from the base branch: %s", rel, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, content.Bytes(), 0644)
+}
+
+func (r Reader) command(name string, args ...string) runner.Command {
+	cmd := runner.New(name, args...)
+	cmd.Dir = r.workDir
+	return cmd
+}
@@ -0,0 +1,110 @@
+package basebranch
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/redact"
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "base", "Dangerfile")
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git show FETCH_HEAD:./ci/Dangerfile": {Output: "warn('base')\n"},
+	})
+
+	reader := New(r, "/project")
+	require.NoError(t, reader.Fetch("main"))
+	require.NoError(t, reader.CopyFile("/project/ci/Dangerfile", dst))
+
+	require.Equal(t, []string{
+		"git rev-parse --is-shallow-repository",
+		"git fetch origin refs/heads/main",
+		"git show FETCH_HEAD:./ci/Dangerfile",
+	}, r.Plan())
+	for _, cmd := range r.Commands {
+		require.Equal(t, "/project", cmd.Dir)
+	}
+
+	content, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "warn('base')\n", string(content))
+}
+
+func TestReader_Fetch(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		shallow      runner.FakeResult
+		expectedPlan []string
+	}{
+		{
+			name:    "full clone",
+			shallow: runner.FakeResult{Output: "false\n"},
+			expectedPlan: []string{
+				"git rev-parse --is-shallow-repository",
+				"git fetch origin refs/heads/main",
+			},
+		},
+		{
+			name:    "shallow clone",
+			shallow: runner.FakeResult{Output: "true\n"},
+			expectedPlan: []string{
+				"git rev-parse --is-shallow-repository",
+				"git fetch --depth=1 origin refs/heads/main",
+			},
+		},
+		{
+			name:    "git without --is-shallow-repository",
+			shallow: runner.FakeResult{Output: "--is-shallow-repository", Err: errors.New("exit status 128")},
+			expectedPlan: []string{
+				"git rev-parse --is-shallow-repository",
+				"git fetch origin refs/heads/main",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{"git rev-parse --is-shallow-repository": scenario.shallow})
+			require.NoError(t, New(r, "/project").Fetch("main"))
+			require.Equal(t, scenario.expectedPlan, r.Plan())
+		})
+	}
+}
+
+func TestReader_CopyFile_RawRunner(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "Dangerfile")
+	fake := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git show FETCH_HEAD:./Dangerfile": {Output: "warn('s3cr3t-token is not a secret here')\n"},
+	})
+
+	reader := New(runner.NewRedactingRunner(fake, redact.New("s3cr3t-token")), "/project").WithRawRunner(fake)
+	require.NoError(t, reader.CopyFile("/project/Dangerfile", dst))
+
+	content, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	require.Equal(t, "warn('s3cr3t-token is not a secret here')\n", string(content))
+}
+
+func TestReader_CopyFile_Missing(t *testing.T) {
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git show FETCH_HEAD:./Dangerfile": {Output: "fatal: path 'Dangerfile' does not exist in 'FETCH_HEAD'", Err: errors.New("exit status 128")},
+	})
+
+	err := New(r, "/project").CopyFile("/project/Dangerfile", filepath.Join(t.TempDir(), "Dangerfile"))
+	require.EqualError(t, err, "failed to read Dangerfile from the base branch: exit status 128")
+}
+
+func TestReader_Exists(t *testing.T) {
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git cat-file -e FETCH_HEAD:./Gemfile.lock": {Err: errors.New("exit status 128")},
+	})
+
+	reader := New(r, "/project")
+	require.True(t, reader.Exists("/project/Gemfile"))
+	require.False(t, reader.Exists("/project/Gemfile.lock"))
+}
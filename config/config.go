@@ -0,0 +1,227 @@
+// Package config holds the inputs of the step.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/network"
+	"github.com/bitrise-steplib/steps-danger/provider"
+	"github.com/bitrise-steplib/steps-danger/pullrequest"
+	"github.com/bitrise-steplib/steps-danger/rules"
+	"github.com/kballard/go-shellquote"
+)
+
+// Config ...
+type Config struct {
+	RepositoryURL     string         `env:"repository_url,required"`
+	AdditionalOptions string         `env:"additional_options"`
+	Rules             string         `env:"rules"`
+	FailurePolicy     failure.Policy `env:"failure_policy,opt[auto,fail,warn_only,never]"`
+	ValidateTokens    bool           `env:"validate_tokens,opt[yes,no]"`
+	CacheDependencies bool           `env:"cache_dependencies,opt[yes,no]"`
+	InstallTimeout    int            `env:"install_timeout,range[0..86400]"`
+	DangerTimeout     int            `env:"danger_timeout,range[0..86400]"`
+	RetryAttempts     int            `env:"retry_attempts,range[1..10]"`
+	RetryBackoff      int            `env:"retry_backoff,range[0..600]"`
+	DryRun            bool           `env:"dry_run,opt[yes,no]"`
+
+	GithubAPIToken   stepconf.Secret `env:"github_api_token"`
+	GithubHost       string          `env:"github_host"`
+	GithubAPIBaseURL string          `env:"github_api_base_url"`
+
+	GithubAppID             string          `env:"github_app_id"`
+	GithubAppInstallationID string          `env:"github_app_installation_id"`
+	GithubAppPrivateKey     stepconf.Secret `env:"github_app_private_key"`
+
+	RateLimitThreshold int `env:"rate_limit_threshold,range[0..100000]"`
+	RateLimitMaxWait   int `env:"rate_limit_max_wait,range[0..3600]"`
+
+	GitlabAPIToken   stepconf.Secret    `env:"gitlab_api_token"`
+	GitlabTokenType  provider.TokenType `env:"gitlab_token_type,opt[personal,project,group,oauth]"`
+	GitlabHost       string             `env:"gitlab_host"`
+	GitlabAPIBaseURL string             `env:"gitlab_api_base_url"`
+
+	PullRequestRepositoryURL string                 `env:"pull_request_repository_url"`
+	PullRequestDestBranch    string                 `env:"pull_request_dest_branch"`
+	ForkPRPolicy             pullrequest.ForkPolicy `env:"fork_pr_policy,opt[run,skip,restricted_token,base_dangerfile]"`
+	ForkPRAPIToken           stepconf.Secret        `env:"fork_pr_api_token"`
+
+	TrustedDangerfile bool `env:"trusted_dangerfile,opt[yes,no]"`
+	TrustedGemfile    bool `env:"trusted_gemfile,opt[yes,no]"`
+
+	ProxyURL     stepconf.Secret `env:"proxy_url"`
+	NoProxy      string          `env:"no_proxy"`
+	CABundlePath string          `env:"ca_bundle_path"`
+
+	DeployDir     string `env:"deploy_dir"`
+	TestResultDir string `env:"test_result_dir"`
+	SARIFPath     string `env:"sarif_path"`
+}
+
+// Parse parses the step inputs from the environment.
+func Parse(envProvider stepconf.EnvProvider) (Config, error) {
+	var cfg Config
+	if err := stepconf.NewEnvParser(envProvider).Parse(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	// An invalid additional_options is reported by the step, the policy falls back to the unquoted options.
+	options, err := shellquote.Split(cfg.AdditionalOptions)
+	if err != nil {
+		options = strings.Fields(cfg.AdditionalOptions)
+	}
+	cfg.FailurePolicy = cfg.FailurePolicy.Resolve(options)
+	return cfg, nil
+}
+
+// Providers returns the git providers, regardless of whether they are configured.
+func (c Config) Providers() []provider.Provider {
+	gitlab := provider.NewGitLab(c.GitlabAPIToken, c.GitlabHost, c.GitlabAPIBaseURL)
+	gitlab.TokenType = c.GitlabTokenType
+
+	return []provider.Provider{
+		provider.NewGitHub(c.GithubAPIToken, c.GithubHost, c.GithubAPIBaseURL),
+		gitlab,
+	}
+}
+
+// Network returns the proxy and CA bundle configuration.
+func (c Config) Network() network.Config {
+	return network.Config{ProxyURL: string(c.ProxyURL), NoProxy: c.NoProxy, CABundlePath: c.CABundlePath}
+}
+
+// Validate checks the inputs, which can not be validated by stepconf.
+func (c Config) Validate() error {
+	if err := c.Network().Validate(); err != nil {
+		return err
+	}
+
+	configured := false
+	for _, p := range c.Providers() {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		configured = configured || p.IsConfigured()
+	}
+
+	if c.GitlabTokenType != "" && c.GitlabTokenType != provider.TokenTypePersonal && c.GitlabAPIToken == "" {
+		return fmt.Errorf("gitlab_token_type is set to %s, but gitlab_api_token is not set", c.GitlabTokenType)
+	}
+
+	if c.UsesGitHubApp() {
+		if c.GithubAppID == "" || c.GithubAppInstallationID == "" || c.GithubAppPrivateKey == "" {
+			return errors.New("if you want to use a GitHub App you need to set all of the github_app_id, the github_app_installation_id and the github_app_private_key")
+		}
+		if c.GithubAPIToken != "" {
+			return errors.New("github_api_token and the GitHub App inputs can not be used together")
+		}
+		configured = true
+	}
+
+	if !configured {
+		return errors.New("none of the API tokens have been set. If you want to use GitHub you need to set github_api_token or the GitHub App inputs. If you want to use GitLab you need to set gitlab_api_token")
+	}
+
+	if c.Rules != "" {
+		if _, err := rules.Parse(c.Rules); err != nil {
+			return err
+		}
+	}
+
+	switch c.ForkPRPolicy {
+	case pullrequest.ForkPolicyRestrictedToken:
+		if c.ForkPRAPIToken == "" {
+			return errors.New("fork_pr_policy is set to restricted_token, but fork_pr_api_token is not set")
+		}
+	case pullrequest.ForkPolicyBaseDangerfile:
+		if c.PullRequestRepositoryURL != "" && c.PullRequestDestBranch == "" {
+			return errors.New("fork_pr_policy is set to base_dangerfile, but pull_request_dest_branch is not set")
+		}
+	}
+	return nil
+}
+
+// UsesGitHubApp returns whether any of the GitHub App inputs is set, instead of github_api_token.
+func (c Config) UsesGitHubApp() bool {
+	return c.GithubAppID != "" || c.GithubAppInstallationID != "" || c.GithubAppPrivateKey != ""
+}
+
+// WithRestrictedToken returns the configuration with the API token of every configured provider
+// replaced by the restricted token used for fork PRs. The GitHub App is not used with a restricted token.
+func (c Config) WithRestrictedToken() Config {
+	if c.GithubAPIToken != "" || c.UsesGitHubApp() {
+		c.GithubAPIToken = c.ForkPRAPIToken
+		c.GithubAppID, c.GithubAppInstallationID, c.GithubAppPrivateKey = "", "", ""
+	}
+	if c.GitlabAPIToken != "" {
+		c.GitlabAPIToken = c.ForkPRAPIToken
+	}
+	return c
+}
+
+// Secrets returns the values of the secret inputs.
+func (c Config) Secrets() []string {
+	var secrets []string
+
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		if secret, ok := v.Field(i).Interface().(stepconf.Secret); ok && secret != "" {
+			secrets = append(secrets, string(secret))
+		}
+	}
+	if password := c.Network().ProxyPassword(); password != "" {
+		secrets = append(secrets, password)
+	}
+	return secrets
+}
+
+// SecretEnvKeys returns the names of the environment variables holding API tokens:
+// the secret inputs of the step and the variables Danger reads the tokens from.
+func (c Config) SecretEnvKeys() []string {
+	var keys []string
+
+	t := reflect.TypeOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type == reflect.TypeOf(stepconf.Secret("")) {
+			keys = append(keys, strings.Split(field.Tag.Get("env"), ",")[0])
+		}
+	}
+
+	for _, p := range c.Providers() {
+		keys = append(keys, p.TokenEnvKey())
+	}
+	return keys
+}
+
+// SecretEnvKeysIn returns SecretEnvKeys, followed by the names of the variables in environ (KEY=value form)
+// holding one of the Secrets under another name. The secret inputs are usually filled from Bitrise secrets,
+// like $GITHUB_TOKEN, which are inherited by every command with their own name.
+func (c Config) SecretEnvKeysIn(environ []string) []string {
+	keys := c.SecretEnvKeys()
+	secrets := c.Secrets()
+	for _, kv := range environ {
+		split := strings.SplitN(kv, "=", 2)
+		if len(split) != 2 || contains(keys, split[0]) {
+			continue
+		}
+		if contains(secrets, split[1]) {
+			keys = append(keys, split[0])
+		}
+	}
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
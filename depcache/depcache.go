@@ -0,0 +1,105 @@
+// Package depcache keeps the gems installed for Danger between builds, using the Bitrise Build Cache.
+package depcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/cache"
+)
+
+// Status is the state of the cached gems, compared to the lockfile of the current build.
+type Status string
+
+// Cache statuses.
+const (
+	StatusHit   Status = "hit"
+	StatusStale Status = "stale"
+	StatusMiss  Status = "miss"
+)
+
+// Dir is a step-controlled directory, the gems are installed into and cached from.
+type Dir struct {
+	root string
+}
+
+// New returns the cache Dir under root.
+func New(root string) Dir {
+	return Dir{root: root}
+}
+
+// BundlePath returns the directory Bundler installs the gems into, passed as BUNDLE_PATH.
+func (d Dir) BundlePath() string {
+	return filepath.Join(d.root, "bundle")
+}
+
+// indicatorPath returns the file holding the checksum of the lockfile the cached gems were installed from.
+// The Bitrise Build Cache only updates the cache, if the indicator changes.
+func (d Dir) indicatorPath() string {
+	return filepath.Join(d.root, "bundle.lock.sha256")
+}
+
+// Status compares the cached gems to the given lockfile.
+func (d Dir) Status(lockfile string) (Status, error) {
+	if d.GemCount() == 0 {
+		return StatusMiss, nil
+	}
+
+	checksum, err := fileChecksum(lockfile)
+	if err != nil {
+		return "", err
+	}
+	indicator, err := ioutil.ReadFile(d.indicatorPath())
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if strings.TrimSpace(string(indicator)) == checksum {
+		return StatusHit, nil
+	}
+	return StatusStale, nil
+}
+
+// GemCount returns the number of gems installed in the bundle path.
+func (d Dir) GemCount() int {
+	gems, err := filepath.Glob(filepath.Join(d.BundlePath(), "ruby", "*", "gems", "*"))
+	if err != nil {
+		return 0
+	}
+	return len(gems)
+}
+
+// Commit records the lockfile the gems were installed from, and adds the bundle path to the cached paths,
+// with the lockfile checksum as change indicator.
+func (d Dir) Commit(c cache.Cache, lockfile string) error {
+	checksum, err := fileChecksum(lockfile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.root, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(d.indicatorPath(), []byte(checksum+"\n"), 0644); err != nil {
+		return err
+	}
+
+	c.IncludePath(d.BundlePath() + " -> " + d.indicatorPath())
+	return c.Commit()
+}
+
+// fileChecksum returns the SHA-256 checksum of the file, or an empty string if it does not exist.
+func fileChecksum(pth string) (string, error) {
+	content, err := ioutil.ReadFile(pth)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,59 @@
+package depcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/stretchr/testify/require"
+)
+
+type mapVariables map[string]string
+
+func (v mapVariables) Get(key string) (string, error) {
+	return v[key], nil
+}
+
+func (v mapVariables) Set(key, value string) error {
+	v[key] = value
+	return nil
+}
+
+func TestDir(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "cache")
+	lockfile := filepath.Join(t.TempDir(), "Gemfile.lock")
+	require.NoError(t, ioutil.WriteFile(lockfile, []byte("danger (8.4.0)"), 0600))
+
+	d := New(root)
+	require.Equal(t, filepath.Join(root, "bundle"), d.BundlePath())
+
+	status, err := d.Status(lockfile)
+	require.NoError(t, err)
+	require.Equal(t, StatusMiss, status)
+
+	// bundle install
+	for _, gem := range []string{"danger-8.4.0", "git-1.9.1"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(d.BundlePath(), "ruby", "2.7.0", "gems", gem), 0755))
+	}
+	require.Equal(t, 2, d.GemCount())
+
+	status, err = d.Status(lockfile)
+	require.NoError(t, err)
+	require.Equal(t, StatusStale, status)
+
+	variables := mapVariables{cache.CacheIncludePathsEnvKey: "/existing/path"}
+	c := cache.Config{VariableGetter: variables, VariableSetters: []cache.VariableSetter{variables}}.NewCache()
+	require.NoError(t, d.Commit(c, lockfile))
+	require.Equal(t, "/existing/path\n"+d.BundlePath()+" -> "+filepath.Join(root, "bundle.lock.sha256")+"\n", variables[cache.CacheIncludePathsEnvKey])
+
+	status, err = d.Status(lockfile)
+	require.NoError(t, err)
+	require.Equal(t, StatusHit, status)
+
+	require.NoError(t, ioutil.WriteFile(lockfile, []byte("danger (8.5.0)"), 0600))
+	status, err = d.Status(lockfile)
+	require.NoError(t, err)
+	require.Equal(t, StatusStale, status)
+}
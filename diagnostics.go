@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/diagnostics"
+	"github.com/bitrise-steplib/steps-danger/runner"
+)
+
+// runState is what Run resolved before failing, to be included in the diagnostics.
+type runState struct {
+	dangerfiles []string
+	bundleEnv   []string
+}
+
+// writeDiagnostics collects the tool versions, the Bundler configuration and the sanitized environment
+// into the diagnostics archive in the deploy directory, and prints a short summary.
+func (s dangerStep) writeDiagnostics(stepErr error) {
+	fmt.Println()
+	log.Infof("Collecting diagnostics")
+
+	bundleEnv := s.state.bundleEnv
+	rubyVersion := s.diagnosticOutput(nil, "ruby", "-v")
+	gemEnv := s.diagnosticOutput(nil, "gem", "env")
+	bundleConfig := s.diagnosticOutput(bundleEnv, "bundle", "config")
+	bundleList := s.diagnosticOutput(bundleEnv, "bundle", "list")
+	dangerVersion := s.diagnosticOutput(bundleEnv, "bundle", "exec", "danger", "--version")
+	dangerfile := strings.Join(s.state.dangerfiles, ", ")
+	if dangerfile == "" {
+		dangerfile = "not resolved, the step failed before"
+	}
+	env, masked := diagnostics.SanitizeEnv(os.Environ(), s.cfg.SecretEnvKeysIn(os.Environ()), s.redactor)
+
+	files := []diagnostics.File{
+		{Name: "error.txt", Content: s.redactor.String(stepErr.Error()) + "\n"},
+		{Name: "ruby-version.txt", Content: rubyVersion},
+		{Name: "gem-env.txt", Content: gemEnv},
+		{Name: "bundle-config.txt", Content: bundleConfig},
+		{Name: "bundle-list.txt", Content: bundleList},
+		{Name: "danger-version.txt", Content: dangerVersion},
+		{Name: "dangerfile.txt", Content: dangerfile + "\n"},
+		{Name: "env.txt", Content: strings.Join(env, "\n") + "\n"},
+	}
+
+	log.Printf("Ruby: %s", firstLine(rubyVersion))
+	log.Printf("Danger: %s", firstLine(dangerVersion))
+	log.Printf("Dangerfile: %s", dangerfile)
+	log.Printf("Environment: %d variables, %d of them masked", len(env), masked)
+
+	if s.cfg.DeployDir == "" {
+		log.Warnf("Deploy directory is not set, %s is not written", diagnostics.FileName)
+		return
+	}
+	pth := filepath.Join(s.cfg.DeployDir, diagnostics.FileName)
+	if err := diagnostics.WriteArchive(pth, files, time.Now()); err != nil {
+		log.Warnf("Failed to write %s: %s", pth, err)
+		return
+	}
+	log.Donef("Diagnostics archive: %s", pth)
+}
+
+// diagnosticOutput returns the output of the command, along with its error if it failed.
+func (s dangerStep) diagnosticOutput(env []string, name string, args ...string) string {
+	cmd := runner.New(name, args...)
+	cmd.Dir = s.workDir
+	cmd.Env = env
+	cmd.Timeout = 30 * time.Second
+
+	out, err := s.runner.Output(cmd)
+	if err != nil {
+		out = strings.TrimSpace(fmt.Sprintf("%s\n%s failed: %s", out, cmd, s.redactor.String(err.Error())))
+	}
+	return out + "\n"
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
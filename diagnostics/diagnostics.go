@@ -0,0 +1,80 @@
+// Package diagnostics writes the information needed to investigate a failed step run into a zip archive.
+package diagnostics
+
+import (
+	"archive/zip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/redact"
+)
+
+// FileName is the name of the archive in the deploy directory.
+const FileName = "danger-diagnostics.zip"
+
+// File is an entry of the archive.
+type File struct {
+	Name    string
+	Content string
+}
+
+// secretNamePattern matches the names of environment variables, which likely hold credentials.
+var secretNamePattern = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|PRIVATE_KEY|API_KEY|ACCESS_KEY|CREDENTIAL|COOKIE|PROXY|AUTH($|_|ORIZATION))`)
+
+// SanitizeEnv returns the environment in KEY=value form, sorted by name. The values of the secretKeys and of
+// the variables named like credentials are replaced by redact.Mask, the rest is masked by the redactor.
+// It also returns the number of fully masked variables.
+func SanitizeEnv(environ []string, secretKeys []string, redactor *redact.Redactor) ([]string, int) {
+	secret := map[string]bool{}
+	for _, key := range secretKeys {
+		secret[key] = true
+	}
+
+	var env []string
+	masked := 0
+	for _, entry := range environ {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], kv[1]
+
+		if value != "" && (secret[key] || secretNamePattern.MatchString(key)) {
+			value = redact.Mask
+			masked++
+		} else {
+			value = redactor.String(value)
+		}
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env, masked
+}
+
+// WriteArchive writes the files into a zip archive at pth.
+func WriteArchive(pth string, files []File, modified time.Time) (err error) {
+	f, err := os.Create(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	w := zip.NewWriter(f)
+	for _, file := range files {
+		entry, err := w.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write([]byte(file.Content)); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
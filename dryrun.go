@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/config"
+)
+
+// printPlan prints what the step resolved, and the commands it would have executed with their redacted environment.
+func (s dangerStep) printPlan(cfg config.Config, dangerfiles []string) {
+	fmt.Println()
+	log.Infof("Dry run plan")
+
+	log.Printf("Repository URL: %s", cfg.RepositoryURL)
+	for _, p := range cfg.Providers() {
+		if p.IsConfigured() {
+			log.Printf("Provider: %s (%s)", p.Name, p.APIURL())
+		}
+	}
+	for _, dangerfile := range dangerfiles {
+		log.Printf("Dangerfile: %s", dangerfile)
+	}
+
+	fmt.Println()
+	log.Printf("Commands not executed:")
+	for _, cmd := range s.dryRun.Commands {
+		log.Printf("$ %s", s.redactor.String(cmd.String()))
+		for _, env := range cmd.Env {
+			log.Printf("  %s", s.redactor.String(env))
+		}
+	}
+
+	fmt.Println()
+	log.Donef("Dry run finished, nothing was installed and Danger did not run")
+}
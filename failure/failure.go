@@ -0,0 +1,168 @@
+// Package failure classifies the reason of a failed Danger step run.
+package failure
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+)
+
+// Kind is the class of a failure.
+type Kind string
+
+// Failure kinds.
+const (
+	KindDependencyInstall   Kind = "dependency_install"
+	KindAuthentication      Kind = "authentication"
+	KindNetwork             Kind = "network"
+	KindDangerfileException Kind = "dangerfile_exception"
+	KindRuleViolation       Kind = "rule_violation"
+	KindTimeout             Kind = "timeout"
+)
+
+// ExitCode returns the exit code of the step for the given failure kind.
+func (k Kind) ExitCode() int {
+	switch k {
+	case KindRuleViolation:
+		return 1
+	case KindDangerfileException:
+		return 2
+	case KindDependencyInstall:
+		return 3
+	case KindAuthentication:
+		return 4
+	case KindNetwork:
+		return 5
+	case KindTimeout:
+		return 6
+	default:
+		return 1
+	}
+}
+
+// Description returns a human readable explanation of the failure kind.
+func (k Kind) Description() string {
+	switch k {
+	case KindRuleViolation:
+		return "Danger reported errors on the pull request."
+	case KindDangerfileException:
+		return "The Dangerfile could not be evaluated, check the exception above."
+	case KindDependencyInstall:
+		return "Failed to install the dependencies required to run Danger, check your Gemfile and Gemfile.lock."
+	case KindAuthentication:
+		return "Danger could not authenticate with the git provider, or the API token does not have the required permissions."
+	case KindNetwork:
+		return "Danger could not reach the git provider, or the API rate limit was exceeded."
+	case KindTimeout:
+		return "Installing the dependencies or running Danger did not finish in time, check the last output lines above."
+	default:
+		return "Danger failed."
+	}
+}
+
+var (
+	authenticationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Bad credentials`),
+		regexp.MustCompile(`401 Unauthorized`),
+		regexp.MustCompile(`403 Forbidden`),
+		regexp.MustCompile(`Octokit::(Unauthorized|Forbidden)`),
+		regexp.MustCompile(`Gitlab::Error::(Unauthorized|Forbidden)`),
+		regexp.MustCompile(`Resource not accessible by (integration|personal access token)`),
+		regexp.MustCompile(`(?i)insufficient[_ ]scope`),
+	}
+	networkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)rate limit exceeded`),
+		regexp.MustCompile(`Octokit::TooManyRequests`),
+		regexp.MustCompile(`Gitlab::Error::TooManyRequests`),
+		regexp.MustCompile(`Faraday::(ConnectionFailed|TimeoutError|SSLError)`),
+		regexp.MustCompile(`Errno::(ECONNRESET|ECONNREFUSED|ETIMEDOUT|EHOSTUNREACH|ENETUNREACH)`),
+		regexp.MustCompile(`Net::(OpenTimeout|ReadTimeout)`),
+		regexp.MustCompile(`SocketError`),
+		regexp.MustCompile(`Failed to open TCP connection`),
+		regexp.MustCompile(`(502 Bad Gateway|503 Service Unavailable|504 Gateway Time-?out)`),
+	}
+	dependencyPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Bundler::GemNotFound`),
+		regexp.MustCompile(`Could not find gem`),
+		regexp.MustCompile(`bundler: command not found: danger`),
+		regexp.MustCompile(`Run .bundle install. to install missing gems`),
+		regexp.MustCompile(`LoadError`),
+	}
+	ruleViolationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Danger has failed this build`),
+	}
+	dangerfileExceptionPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Invalid .Dangerfile. file`),
+	}
+	transientPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`Gem::RemoteFetcher::(FetchError|UnknownHostError)`),
+		regexp.MustCompile(`Bundler::(HTTPError|Fetcher::NetworkDownError)`),
+		regexp.MustCompile(`Faraday::(ConnectionFailed|TimeoutError)`),
+		regexp.MustCompile(`Errno::(ECONNRESET|ETIMEDOUT|EHOSTUNREACH|ENETUNREACH)`),
+		regexp.MustCompile(`(?i)connection reset by peer`),
+		regexp.MustCompile(`Net::(OpenTimeout|ReadTimeout)`),
+		regexp.MustCompile(`(502 Bad Gateway|503 Service Unavailable|504 Gateway Time-?out)`),
+		regexp.MustCompile(`(Bad Gateway|Service Unavailable|Gateway Time-?out) 50[234]`),
+	}
+)
+
+// IsTransient returns whether the output of a failed command points to a temporary network or server error,
+// which is worth retrying. Dangerfile exceptions, rule violations and rejected credentials are never transient.
+func IsTransient(output string) bool {
+	for _, permanent := range [][]*regexp.Regexp{dangerfileExceptionPatterns, ruleViolationPatterns, authenticationPatterns} {
+		if matchesAny(output, permanent) {
+			return false
+		}
+	}
+	return matchesAny(output, transientPatterns)
+}
+
+// ClassifyDangerRun returns the kind of a failed `bundle exec danger` run, based on its output and
+// the results dumped by the wrapper Dangerfile. dangerResults is nil, if the Dangerfile did not finish.
+func ClassifyDangerRun(output string, dangerResults *results.Results) Kind {
+	switch {
+	case matchesAny(output, authenticationPatterns):
+		return KindAuthentication
+	case matchesAny(output, networkPatterns):
+		return KindNetwork
+	case dangerResults != nil && dangerResults.ErrorsCount() > 0:
+		return KindRuleViolation
+	case matchesAny(output, ruleViolationPatterns):
+		return KindRuleViolation
+	case matchesAny(output, dependencyPatterns):
+		return KindDependencyInstall
+	default:
+		return KindDangerfileException
+	}
+}
+
+func matchesAny(output string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(output) {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is a failure of the given kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// Errorf returns an Error of the given kind.
+func Errorf(kind Kind, format string, v ...interface{}) *Error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, v...)}
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
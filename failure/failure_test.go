@@ -0,0 +1,132 @@
+package failure
+
+import (
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyDangerRun(t *testing.T) {
+	withErrors := &results.Results{Dangerfiles: []results.Dangerfile{{Errors: []results.Violation{{Message: "Big PR"}}}}}
+	withWarnings := &results.Results{Dangerfiles: []results.Dangerfile{{Warnings: []results.Violation{{Message: "No tests"}}}}}
+
+	scenarios := []struct {
+		name          string
+		output        string
+		dangerResults *results.Results
+		expected      Kind
+	}{
+		{
+			name:          "errors reported",
+			output:        "Danger has failed this build. \nFound 1 error.",
+			dangerResults: withErrors,
+			expected:      KindRuleViolation,
+		},
+		{
+			name:     "errors reported without results",
+			output:   "Danger has failed this build. \nFound 1 error.",
+			expected: KindRuleViolation,
+		},
+		{
+			name:          "bad credentials",
+			output:        "Octokit::Unauthorized: GET https://api.github.com/repos/org/repo/pulls/1: 401 - Bad credentials",
+			dangerResults: withWarnings,
+			expected:      KindAuthentication,
+		},
+		{
+			name:     "gitlab forbidden",
+			output:   "Gitlab::Error::Forbidden: Server responded with code 403",
+			expected: KindAuthentication,
+		},
+		{
+			name:     "rate limit",
+			output:   "Octokit::TooManyRequests: GET https://api.github.com/rate_limit: 403 - API rate limit exceeded",
+			expected: KindNetwork,
+		},
+		{
+			name:     "connection failed",
+			output:   "Faraday::ConnectionFailed: Failed to open TCP connection to git.corp.evilcorp.com:443",
+			expected: KindNetwork,
+		},
+		{
+			name:     "missing gem",
+			output:   "Could not find gem 'danger' in locally installed gems.\nRun `bundle install` to install missing gems.",
+			expected: KindDependencyInstall,
+		},
+		{
+			name:     "dangerfile exception",
+			output:   "[!] Invalid `Dangerfile` file: undefined local variable or method `gitt'",
+			expected: KindDangerfileException,
+		},
+		{
+			name:          "unknown failure with warnings only",
+			output:        "",
+			dangerResults: withWarnings,
+			expected:      KindDangerfileException,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			require.Equal(t, scenario.expected, ClassifyDangerRun(scenario.output, scenario.dangerResults))
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		output   string
+		expected bool
+	}{
+		{
+			name:     "gem fetch error",
+			output:   "Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503 (https://rubygems.org/gems/danger-8.4.0.gem)",
+			expected: true,
+		},
+		{
+			name:     "bundler connection reset",
+			output:   "Bundler::HTTPError Could not fetch specs from https://rubygems.org/ due to underlying error <Errno::ECONNRESET: Connection reset by peer>",
+			expected: true,
+		},
+		{
+			name:     "bad gateway",
+			output:   "Octokit::BadGateway: GET https://api.github.com/repos/org/repo/pulls/1: 502 Bad Gateway",
+			expected: true,
+		},
+		{
+			name:     "dangerfile exception after a network error",
+			output:   "Faraday::ConnectionFailed: execution expired\n[!] Invalid `Dangerfile` file: undefined local variable or method `gitt'",
+			expected: false,
+		},
+		{
+			name:   "rule violation",
+			output: "Danger has failed this build. \nFound 1 error.",
+		},
+		{
+			name:   "bad credentials",
+			output: "Octokit::Unauthorized: GET https://api.github.com/user: 401 - Bad credentials",
+		},
+		{
+			name:   "missing gem",
+			output: "Could not find gem 'danger' in locally installed gems.",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			require.Equal(t, scenario.expected, IsTransient(scenario.output))
+		})
+	}
+}
+
+func TestKind_ExitCode(t *testing.T) {
+	codes := map[int]Kind{}
+	for _, kind := range []Kind{KindRuleViolation, KindDangerfileException, KindDependencyInstall, KindAuthentication, KindNetwork, KindTimeout} {
+		code := kind.ExitCode()
+		require.NotZero(t, code)
+		require.NotContains(t, codes, code, "%s and %s share exit code %d", kind, codes[code], code)
+		codes[code] = kind
+	}
+}
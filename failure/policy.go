@@ -0,0 +1,45 @@
+package failure
+
+import "strings"
+
+// Policy controls which failures fail the step.
+type Policy string
+
+// Failure policies.
+const (
+	// PolicyFail fails the step on rule violations and on any other failure.
+	PolicyFail Policy = "fail"
+	// PolicyWarnOnly reports rule violations as warnings, but fails the step on any other failure.
+	PolicyWarnOnly Policy = "warn_only"
+	// PolicyNever never fails the step, not even if Danger could not run.
+	PolicyNever Policy = "never"
+	// PolicyAuto follows Danger's --fail-on-errors option, like the step did before the policy was introduced:
+	// PolicyFail if it is set to true, PolicyWarnOnly otherwise.
+	PolicyAuto Policy = "auto"
+)
+
+// Resolve returns the policy PolicyAuto (or an unset policy) stands for, given the additional options of Danger.
+// Other policies are returned as is.
+func (p Policy) Resolve(dangerOptions []string) Policy {
+	if p != PolicyAuto && p != "" {
+		return p
+	}
+	for _, option := range dangerOptions {
+		if strings.TrimSpace(option) == "--fail-on-errors=true" {
+			return PolicyFail
+		}
+	}
+	return PolicyWarnOnly
+}
+
+// ShouldFail returns whether a failure of the given kind fails the step.
+func (p Policy) ShouldFail(kind Kind) bool {
+	switch p {
+	case PolicyNever:
+		return false
+	case PolicyWarnOnly:
+		return kind != KindRuleViolation
+	default:
+		return true
+	}
+}
@@ -0,0 +1,30 @@
+package failure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_ShouldFail(t *testing.T) {
+	infrastructureKinds := []Kind{KindDangerfileException, KindDependencyInstall, KindAuthentication, KindNetwork, KindTimeout}
+
+	for _, kind := range append(infrastructureKinds, KindRuleViolation) {
+		require.True(t, PolicyFail.ShouldFail(kind), kind)
+		require.False(t, PolicyNever.ShouldFail(kind), kind)
+	}
+
+	require.False(t, PolicyWarnOnly.ShouldFail(KindRuleViolation))
+	for _, kind := range infrastructureKinds {
+		require.True(t, PolicyWarnOnly.ShouldFail(kind), kind)
+	}
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	require.Equal(t, PolicyFail, PolicyAuto.Resolve([]string{"--verbose", "--fail-on-errors=true"}))
+	require.Equal(t, PolicyFail, Policy("").Resolve([]string{"--fail-on-errors=true"}))
+	require.Equal(t, PolicyWarnOnly, PolicyAuto.Resolve([]string{"--fail-on-errors=false"}))
+	require.Equal(t, PolicyWarnOnly, PolicyAuto.Resolve(nil))
+	require.Equal(t, PolicyNever, PolicyNever.Resolve([]string{"--fail-on-errors=true"}))
+	require.Equal(t, PolicyFail, PolicyFail.Resolve(nil))
+}
@@ -0,0 +1,52 @@
+package failure
+
+import (
+	"strings"
+	"sync"
+)
+
+// OutputTail is an io.Writer keeping the last bytes written to it, to classify and report failures.
+type OutputTail struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+}
+
+// NewOutputTail returns an OutputTail keeping at most limit bytes.
+func NewOutputTail(limit int) *OutputTail {
+	return &OutputTail{limit: limit}
+}
+
+// Write implements io.Writer.
+func (t *OutputTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.data = append(t.data, p...)
+	if len(t.data) > t.limit {
+		t.data = t.data[len(t.data)-t.limit:]
+	}
+	return len(p), nil
+}
+
+// String returns the kept output.
+func (t *OutputTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return string(t.data)
+}
+
+// LastLines returns the last n non-empty lines of the kept output.
+func (t *OutputTail) LastLines(n int) []string {
+	var lines []string
+	for _, line := range strings.Split(t.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,21 @@
+package failure
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputTail(t *testing.T) {
+	tail := NewOutputTail(10)
+
+	_, err := fmt.Fprint(tail, "first\nsecond\n")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(tail, "\nthird\n")
+	require.NoError(t, err)
+
+	require.Equal(t, "nd\n\nthird\n", tail.String())
+	require.Equal(t, []string{"nd", "third"}, tail.LastLines(2))
+	require.Equal(t, []string{"third"}, tail.LastLines(1))
+}
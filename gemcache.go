@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/depcache"
+)
+
+// gemCache is the state of the cached gems, before they are installed.
+type gemCache struct {
+	dir      depcache.Dir
+	lockfile string
+	cached   int
+}
+
+// openGemCache logs whether the gems installed by previous builds can be reused for the given lockfile.
+func (s dangerStep) openGemCache(lockfile string) gemCache {
+	c := gemCache{dir: depcache.New(s.cacheDir), lockfile: lockfile}
+	c.cached = c.dir.GemCount()
+
+	status, err := c.dir.Status(lockfile)
+	if err != nil {
+		log.Warnf("Failed to check the gem cache: %s", err)
+		return c
+	}
+
+	switch status {
+	case depcache.StatusHit:
+		log.Donef("Gem cache hit: %d gems restored, the lockfile did not change", c.cached)
+	case depcache.StatusStale:
+		log.Warnf("Gem cache is stale: %d gems restored, the lockfile changed since they were cached", c.cached)
+	default:
+		log.Warnf("Gem cache miss: no gems restored")
+	}
+	return c
+}
+
+// commitGemCache logs how many gems were reused and adds the bundle path to the Bitrise Build Cache.
+// Failures are only logged, as they do not affect the Danger run.
+func (s dangerStep) commitGemCache(c gemCache) {
+	installed := c.dir.GemCount() - c.cached
+	if installed < 0 {
+		installed = 0
+	}
+	log.Printf("Gems: %d reused from the cache, %d installed", c.cached, installed)
+
+	if err := c.dir.Commit(s.cacheConfig.NewCache(), c.lockfile); err != nil {
+		log.Warnf("Failed to add the gems to the cache: %s", err)
+		return
+	}
+	log.Donef("Gems added to the cache: %s", c.dir.BundlePath())
+}
@@ -0,0 +1,146 @@
+// Package githubapp authenticates as a GitHub App installation, instead of with a personal access token.
+package githubapp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/failure"
+)
+
+// The JWT is backdated to allow for clock drift, GitHub accepts at most 10 minutes of validity.
+const (
+	jwtBackdate = time.Minute
+	jwtValidity = 9 * time.Minute
+)
+
+// App is a GitHub App installed on the repository's owner.
+type App struct {
+	ID             string
+	InstallationID string
+	PrivateKey     *rsa.PrivateKey
+}
+
+// ParsePrivateKey parses the PEM encoded private key of the App, generated on GitHub in PKCS #1 form.
+func ParsePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemKey)))
+	if block == nil {
+		return nil, errors.New("the private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// JWT returns the RS256 signed JSON Web Token the App authenticates with, issued at now.
+func (a App) JWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-jwtBackdate).Unix(),
+		"exp": now.Add(jwtValidity).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the JWT: %s", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Client exchanges the App's JWT for installation tokens.
+type Client struct {
+	client *http.Client
+	apiURL string
+	now    func() time.Time
+}
+
+// NewClient returns a Client calling the GitHub API at apiURL.
+func NewClient(client *http.Client, apiURL string) Client {
+	return Client{client: client, apiURL: strings.TrimSuffix(apiURL, "/"), now: time.Now}
+}
+
+// InstallationToken returns a short-lived token of the App's installation.
+// Failures are returned as *failure.Error.
+func (c Client) InstallationToken(app App) (string, error) {
+	jwt, err := app.JWT(c.now())
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%s/access_tokens", c.apiURL, app.InstallationID)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub App token request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", failure.Errorf(failure.KindNetwork, "failed to reach GitHub (%s): %s", url, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		kind := failure.KindAuthentication
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			kind = failure.KindNetwork
+		}
+		return "", failure.Errorf(kind, "failed to create GitHub App installation token (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		Token       string            `json:"token"`
+		ExpiresAt   time.Time         `json:"expires_at"`
+		Permissions map[string]string `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", failure.Errorf(failure.KindNetwork, "failed to parse the GitHub App installation token response: %s", err)
+	}
+
+	if token.Permissions["pull_requests"] != "write" && token.Permissions["issues"] != "write" {
+		return "", failure.Errorf(failure.KindAuthentication, "the GitHub App installation is missing the pull_requests or issues write permission required to comment on pull requests")
+	}
+
+	log.Donef("Created GitHub App installation token, expires at %s", token.ExpiresAt.Format(time.RFC3339))
+	return token.Token, nil
+}
@@ -0,0 +1,145 @@
+package githubapp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key := generateKey(t)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := ParsePrivateKey(string(pkcs1))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	parsed, err = ParsePrivateKey(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes})))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	_, err = ParsePrivateKey("not a key")
+	require.EqualError(t, err, "the private key is not PEM encoded")
+}
+
+func TestApp_JWT(t *testing.T) {
+	key := generateKey(t)
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	jwt, err := App{ID: "12345", PrivateKey: key}.JWT(now)
+	require.NoError(t, err)
+
+	claims := verifyJWT(t, jwt, &key.PublicKey)
+	require.Equal(t, map[string]interface{}{
+		"iss": "12345",
+		"iat": float64(now.Add(-time.Minute).Unix()),
+		"exp": float64(now.Add(9 * time.Minute).Unix()),
+	}, claims)
+}
+
+func TestClient_InstallationToken(t *testing.T) {
+	key := generateKey(t)
+	app := App{ID: "12345", InstallationID: "678", PrivateKey: key}
+
+	scenarios := []struct {
+		name          string
+		status        int
+		body          string
+		expectedToken string
+		expectedKind  failure.Kind
+	}{
+		{
+			name:          "created",
+			status:        http.StatusCreated,
+			body:          `{"token":"ghs_installation","expires_at":"2021-08-01T13:00:00Z","permissions":{"pull_requests":"write","contents":"read"}}`,
+			expectedToken: "ghs_installation",
+		},
+		{
+			name:         "missing write permission",
+			status:       http.StatusCreated,
+			body:         `{"token":"ghs_installation","expires_at":"2021-08-01T13:00:00Z","permissions":{"pull_requests":"read"}}`,
+			expectedKind: failure.KindAuthentication,
+		},
+		{
+			name:         "installation not found",
+			status:       http.StatusNotFound,
+			body:         `{"message":"Integration not found"}`,
+			expectedKind: failure.KindAuthentication,
+		},
+		{
+			name:         "server error",
+			status:       http.StatusServiceUnavailable,
+			expectedKind: failure.KindNetwork,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/api/v3/app/installations/678/access_tokens", r.URL.Path)
+				require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "))
+				claims := verifyJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+				require.Equal(t, "12345", claims["iss"])
+
+				w.WriteHeader(scenario.status)
+				_, _ = fmt.Fprint(w, scenario.body)
+			}))
+			defer server.Close()
+
+			token, err := NewClient(server.Client(), server.URL+"/api/v3/").InstallationToken(app)
+			if scenario.expectedKind == "" {
+				require.NoError(t, err)
+				require.Equal(t, scenario.expectedToken, token)
+				return
+			}
+
+			var failureErr *failure.Error
+			require.True(t, errors.As(err, &failureErr), "%v", err)
+			require.Equal(t, scenario.expectedKind, failureErr.Kind)
+		})
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func verifyJWT(t *testing.T, jwt string, key *rsa.PublicKey) map[string]interface{} {
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature))
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"alg":"RS256","typ":"JWT"}`, string(header))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	return claims
+}
@@ -0,0 +1,216 @@
+// Package installer installs Bundler and the gems required to run Danger.
+package installer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/runner"
+)
+
+// Installer installs the dependencies of the project in workDir.
+type Installer struct {
+	runner     runner.Runner
+	workDir    string
+	gemfile    string
+	bundlePath string
+	deadline   time.Time
+	output     io.Writer
+}
+
+// New returns an Installer.
+func New(r runner.Runner, workDir string) Installer {
+	return Installer{runner: r, workDir: workDir}
+}
+
+// WithGemfile returns an Installer, which installs the gems of the given Gemfile
+// instead of the one in workDir, by setting BUNDLE_GEMFILE.
+func (i Installer) WithGemfile(pth string) Installer {
+	i.gemfile = pth
+	return i
+}
+
+// WithBundlePath returns an Installer, which installs the gems into the given directory
+// instead of the system gems, by setting BUNDLE_PATH.
+func (i Installer) WithBundlePath(pth string) Installer {
+	i.bundlePath = pth
+	return i
+}
+
+// WithDeadline returns an Installer, which kills the process group of its commands still running at the deadline.
+// The commands fail with *runner.TimeoutError in this case.
+func (i Installer) WithDeadline(deadline time.Time) Installer {
+	i.deadline = deadline
+	return i
+}
+
+// WithOutput returns an Installer, which copies the output of the installing commands to w too.
+func (i Installer) WithOutput(w io.Writer) Installer {
+	i.output = w
+	return i
+}
+
+// Env returns the Bundler configuration of the Installer in KEY=value form,
+// which has to be passed to `bundle exec` too.
+func (i Installer) Env() []string {
+	var env []string
+	if i.gemfile != "" {
+		env = append(env, "BUNDLE_GEMFILE="+i.gemfile)
+	}
+	if i.bundlePath != "" {
+		env = append(env, "BUNDLE_PATH="+i.bundlePath)
+	}
+	return env
+}
+
+// Gemfile returns the path of the Gemfile the gems are installed from.
+func (i Installer) Gemfile() string {
+	if i.gemfile != "" {
+		return i.gemfile
+	}
+	return filepath.Join(i.workDir, "Gemfile")
+}
+
+// BundlerVersion returns the Bundler version the Gemfile.lock was bundled with.
+func (i Installer) BundlerVersion() (gems.Version, error) {
+	lockFileContent, err := fileutil.ReadStringFromFile(i.Gemfile() + ".lock")
+	if err != nil {
+		log.Warnf("Could not read from Gemfile.lock, error: %s", err)
+		log.Infof("Using unspecified bundler version")
+		return gems.Version{}, nil
+	}
+
+	return gems.ParseBundlerVersion(lockFileContent)
+}
+
+// IsBundlerInstalled returns whether the given Bundler version is installed.
+func (i Installer) IsBundlerInstalled(version gems.Version) (bool, error) {
+	out, err := i.runner.Output(i.command("gem", "list"))
+	if err != nil {
+		return false, fmt.Errorf("%s: error: %w", out, err)
+	}
+
+	return findGemInList(out, "bundler", version.Version)
+}
+
+// InstallBundler installs the given Bundler version.
+func (i Installer) InstallBundler(version gems.Version) error {
+	args := []string{"install", "bundler", "--force", "--no-document"}
+	if version.Found {
+		args = append(args, "--version", version.Version)
+	}
+	return i.run(i.command("gem", args...))
+}
+
+// BundleInstall installs the gems of the Gemfile.
+func (i Installer) BundleInstall() error {
+	cmd := i.command("bundle", "install")
+	cmd.Env = i.Env()
+	return i.run(cmd)
+}
+
+// IsBundleSatisfied returns whether every gem of the Gemfile is installed, according to `bundle check`.
+// With --dry-run, the check does not write the Gemfile.lock.
+func (i Installer) IsBundleSatisfied() bool {
+	cmd := i.command("bundle", "check", "--dry-run")
+	cmd.Env = i.Env()
+	log.Donef("$ %s", cmd)
+	_, err := i.runner.Output(cmd)
+	return err == nil
+}
+
+// EnsureGems runs `bundle install`, unless the gems of the Gemfile are already installed.
+// The decision and the time spent are logged.
+func (i Installer) EnsureGems() error {
+	start := time.Now()
+	satisfied := i.IsBundleSatisfied()
+	checkDuration := time.Since(start)
+
+	if satisfied {
+		log.Donef("The gems are already installed, skipping bundle install (bundle check took %s)", formatDuration(checkDuration))
+		return nil
+	}
+	log.Printf("Some gems are missing, installing them (bundle check took %s)", formatDuration(checkDuration))
+
+	start = time.Now()
+	if err := i.BundleInstall(); err != nil {
+		return err
+	}
+	log.Donef("bundle install took %s", formatDuration(time.Since(start)))
+	return nil
+}
+
+// EnsureBundler installs the Bundler version of the Gemfile.lock, if it is not installed yet.
+func (i Installer) EnsureBundler() error {
+	version, err := i.BundlerVersion()
+	if err != nil {
+		return fmt.Errorf("could not determine required bundler version, error: %s", err)
+	}
+
+	installed, err := i.IsBundlerInstalled(version)
+	if err != nil {
+		return fmt.Errorf("failed to check bundler, error: %w", err)
+	}
+
+	if !installed {
+		log.Warnf(`Bundler is not installed`)
+		fmt.Println()
+		log.Printf("Installing Bundler")
+
+		if err := i.InstallBundler(version); err != nil {
+			return fmt.Errorf("command failed, error: %w", err)
+		}
+	}
+	log.Printf("Bundler installed")
+
+	return nil
+}
+
+func (i Installer) command(name string, args ...string) runner.Command {
+	cmd := runner.New(name, args...)
+	cmd.Dir = i.workDir
+	if !i.deadline.IsZero() {
+		// A command started after the deadline is killed right away.
+		cmd.Timeout = time.Until(i.deadline)
+		if cmd.Timeout <= 0 {
+			cmd.Timeout = time.Millisecond
+		}
+	}
+	return cmd
+}
+
+func (i Installer) run(cmd runner.Command) error {
+	if i.output != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, i.output)
+		cmd.Stderr = io.MultiWriter(os.Stderr, i.output)
+	}
+	log.Donef("$ %s", cmd)
+	fmt.Println()
+	return i.runner.Run(cmd)
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// findGemInList looks up the gem in the `gem list` output, for example: minitest (5.10.1, 5.9.1, 5.9.0).
+func findGemInList(gemList, gem, version string) (bool, error) {
+	re := regexp.MustCompile(fmt.Sprintf(`^%s \(.*%s.*\)`, regexp.QuoteMeta(gem), regexp.QuoteMeta(version)))
+
+	scanner := bufio.NewScanner(strings.NewReader(gemList))
+	for scanner.Scan() {
+		if re.MatchString(scanner.Text()) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,168 @@
+package installer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/stretchr/testify/require"
+)
+
+const gemfileLock = `GEM
+  remote: https://rubygems.org/
+  specs:
+    danger (8.4.0)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  danger
+
+BUNDLED WITH
+   2.2.24
+`
+
+func TestInstaller_EnsureBundler(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		gemfileLock  string
+		gemList      string
+		expectedPlan []string
+	}{
+		{
+			name:         "locked version installed",
+			gemfileLock:  gemfileLock,
+			gemList:      "bundler (2.2.24, 1.17.3)\ndanger (8.4.0)",
+			expectedPlan: []string{"gem list"},
+		},
+		{
+			name:         "locked version not installed",
+			gemfileLock:  gemfileLock,
+			gemList:      "bundler (1.17.3)",
+			expectedPlan: []string{"gem list", "gem install bundler --force --no-document --version 2.2.24"},
+		},
+		{
+			name:         "no Gemfile.lock, bundler installed",
+			gemList:      "bundler (1.17.3)",
+			expectedPlan: []string{"gem list"},
+		},
+		{
+			name:         "no Gemfile.lock, bundler not installed",
+			gemList:      "danger (8.4.0)",
+			expectedPlan: []string{"gem list", "gem install bundler --force --no-document"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			if scenario.gemfileLock != "" {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "Gemfile.lock"), []byte(scenario.gemfileLock), 0600))
+			}
+
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: scenario.gemList}})
+			require.NoError(t, New(r, workDir).EnsureBundler())
+			require.Equal(t, scenario.expectedPlan, r.Plan())
+
+			for _, cmd := range r.Commands {
+				require.Equal(t, workDir, cmd.Dir)
+			}
+		})
+	}
+}
+
+func TestInstaller_BundleInstall(t *testing.T) {
+	r := runner.NewFakeRunner(nil)
+	require.NoError(t, New(r, "/project").BundleInstall())
+	require.Equal(t, []string{"bundle install"}, r.Plan())
+}
+
+func TestInstaller_WithGemfile(t *testing.T) {
+	gemfile := filepath.Join(t.TempDir(), "Gemfile")
+	require.NoError(t, ioutil.WriteFile(gemfile+".lock", []byte(gemfileLock), 0600))
+
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (1.17.3)"}})
+	i := New(r, "/project").WithGemfile(gemfile)
+	require.Equal(t, gemfile, i.Gemfile())
+	require.NoError(t, i.EnsureBundler())
+	require.NoError(t, i.BundleInstall())
+
+	require.Equal(t, []string{
+		"gem list",
+		"gem install bundler --force --no-document --version 2.2.24",
+		"bundle install",
+	}, r.Plan())
+	require.Equal(t, []string{"BUNDLE_GEMFILE=" + gemfile}, r.Commands[2].Env)
+
+	i = i.WithBundlePath("/cache/bundle")
+	require.Equal(t, []string{"BUNDLE_GEMFILE=" + gemfile, "BUNDLE_PATH=/cache/bundle"}, i.Env())
+	require.Empty(t, New(r, "/project").Env())
+}
+
+func TestInstaller_EnsureGems(t *testing.T) {
+	r := runner.NewFakeRunner(nil)
+	require.NoError(t, New(r, "/project").WithBundlePath("/cache/bundle").EnsureGems())
+	require.Equal(t, []string{"bundle check --dry-run"}, r.Plan())
+	require.Equal(t, []string{"BUNDLE_PATH=/cache/bundle"}, r.Commands[0].Env)
+
+	r = runner.NewFakeRunner(map[string]runner.FakeResult{
+		"bundle check --dry-run": {Output: "Bundler can't satisfy your Gemfile's dependencies.", Err: errors.New("exit status 1")},
+	})
+	require.NoError(t, New(r, "/project").EnsureGems())
+	require.Equal(t, []string{"bundle check --dry-run", "bundle install"}, r.Plan())
+
+	r = runner.NewFakeRunner(map[string]runner.FakeResult{
+		"bundle check --dry-run": {Err: errors.New("exit status 1")},
+		"bundle install":         {Err: errors.New("exit status 5")},
+	})
+	require.EqualError(t, New(r, "/project").EnsureGems(), "exit status 5")
+}
+
+func TestInstaller_WithDeadline(t *testing.T) {
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"bundle check --dry-run": {Err: errors.New("exit status 1")},
+		"bundle install":         {Output: "Fetching gem metadata from https://rubygems.org/\n", Err: &runner.TimeoutError{Command: "bundle install", Timeout: time.Minute}},
+	})
+	var output bytes.Buffer
+	err := New(r, "/project").WithDeadline(time.Now().Add(time.Minute)).WithOutput(&output).EnsureGems()
+
+	var timeoutErr *runner.TimeoutError
+	require.True(t, errors.As(err, &timeoutErr), "%v", err)
+	require.Equal(t, "Fetching gem metadata from https://rubygems.org/\n", output.String())
+	for _, cmd := range r.Commands {
+		require.True(t, cmd.Timeout > 0 && cmd.Timeout <= time.Minute, "%s: %s", cmd, cmd.Timeout)
+	}
+
+	r = runner.NewFakeRunner(nil)
+	require.NoError(t, New(r, "/project").WithDeadline(time.Now().Add(-time.Second)).BundleInstall())
+	require.Equal(t, time.Millisecond, r.Commands[0].Timeout)
+
+	r = runner.NewFakeRunner(nil)
+	require.NoError(t, New(r, "/project").BundleInstall())
+	require.Zero(t, r.Commands[0].Timeout)
+}
+
+func Test_findGemInList(t *testing.T) {
+	gemList := "bundler (2.2.24, 1.17.3)\nbundler-audit (0.9.0)\nminitest (5.10.1)"
+
+	for _, scenario := range []struct {
+		gem      string
+		version  gems.Version
+		expected bool
+	}{
+		{"bundler", gems.Version{}, true},
+		{"bundler", gems.Version{Version: "1.17.3", Found: true}, true},
+		{"bundler", gems.Version{Version: "2.3.0", Found: true}, false},
+		{"danger", gems.Version{}, false},
+	} {
+		found, err := findGemInList(gemList, scenario.gem, scenario.version.Version)
+		require.NoError(t, err)
+		require.Equal(t, scenario.expected, found, "%s %s", scenario.gem, scenario.version.Version)
+	}
+}
@@ -0,0 +1,237 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubbedExecutables are put on PATH by every harness, the ones without a scripted response succeed without output.
+var stubbedExecutables = []string{"ruby", "gem", "bundle", "danger", "npx", "git", "envman"}
+
+// stepBinary is the step built by TestMain.
+var stepBinary string
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	buildDir, err := ioutil.TempDir("", "danger-step-build")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer func() {
+		if err := os.RemoveAll(buildDir); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	stepBinary = filepath.Join(buildDir, "step")
+	cmd := exec.Command("go", "build", "-o", stepBinary, "github.com/bitrise-steplib/steps-danger")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Printf("Failed to build the step: %s\n%s\n", err, out)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// response is the scripted behaviour of a stub, when its arguments match the pattern.
+type response struct {
+	// Pattern is a shell case pattern, matched against the space separated arguments.
+	Pattern  string
+	Stdout   string
+	ExitCode int
+	// Script is a shell snippet executed before printing Stdout, the arguments are available as "$@".
+	Script string
+}
+
+// call is a recorded invocation of a stub.
+type call struct {
+	Name  string
+	Args  []string
+	Env   map[string]string
+	Stdin string
+}
+
+// String returns the invocation in `name arg1 arg2` form.
+func (c call) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// harness runs the step binary in a working directory with a Dangerfile, with the stubs on PATH.
+type harness struct {
+	t         *testing.T
+	workDir   string
+	stubDir   string
+	callsDir  string
+	responses map[string][]response
+}
+
+func newHarness(t *testing.T) *harness {
+	root := t.TempDir()
+	h := &harness{
+		t:         t,
+		workDir:   filepath.Join(root, "project"),
+		stubDir:   filepath.Join(root, "bin"),
+		callsDir:  filepath.Join(root, "calls"),
+		responses: map[string][]response{},
+	}
+	for _, dir := range []string{h.workDir, h.stubDir, h.callsDir} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	h.writeFile("Dangerfile", `message("Hi")`)
+	return h
+}
+
+// stub adds a scripted response to the given executable. The responses are matched in order.
+func (h *harness) stub(name string, r response) {
+	h.responses[name] = append(h.responses[name], r)
+}
+
+// writeFile writes a file in the project's working directory.
+func (h *harness) writeFile(name, content string) {
+	require.NoError(h.t, ioutil.WriteFile(filepath.Join(h.workDir, name), []byte(content), 0644))
+}
+
+// dangerfileCheck returns the syntax check of the project's Dangerfile in `name arg1 arg2` form.
+func (h *harness) dangerfileCheck() string {
+	return "ruby -c " + filepath.Join(h.workDir, "Dangerfile")
+}
+
+// run runs the step with the given inputs and returns its combined output and exit code.
+func (h *harness) run(inputs map[string]string) (string, int) {
+	for _, name := range stubbedExecutables {
+		require.NoError(h.t, ioutil.WriteFile(filepath.Join(h.stubDir, name), []byte(h.stubScript(name)), 0755))
+	}
+
+	cmd := exec.Command(stepBinary)
+	cmd.Dir = h.workDir
+	cmd.Env = []string{
+		"PATH=" + h.stubDir + ":/usr/bin:/bin",
+		"HOME=" + h.workDir,
+		"TMPDIR=" + h.t.TempDir(),
+		"STUB_CALLS_DIR=" + h.callsDir,
+	}
+	for key, value := range inputs {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode()
+	}
+	require.NoError(h.t, err, string(out))
+	return string(out), 0
+}
+
+// calls returns the recorded stub invocations in order, except the envman ones.
+func (h *harness) calls() []call {
+	var calls []call
+	for _, c := range h.allCalls() {
+		if c.Name != "envman" {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// outputs returns the outputs exported with envman.
+func (h *harness) outputs() map[string]string {
+	outputs := map[string]string{}
+	for _, c := range h.allCalls() {
+		if c.Name == "envman" && len(c.Args) == 3 && c.Args[0] == "add" && c.Args[1] == "--key" {
+			outputs[c.Args[2]] = c.Stdin
+		}
+	}
+	return outputs
+}
+
+// plan returns the recorded stub invocations in `name arg1 arg2` form.
+func (h *harness) plan() []string {
+	var plan []string
+	for _, c := range h.calls() {
+		plan = append(plan, c.String())
+	}
+	return plan
+}
+
+func (h *harness) allCalls() []call {
+	entries, err := ioutil.ReadDir(h.callsDir)
+	require.NoError(h.t, err)
+
+	var ids []string
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".name" {
+			ids = append(ids, strings.TrimSuffix(entry.Name(), ".name"))
+		}
+	}
+	sort.Strings(ids)
+
+	var calls []call
+	for _, id := range ids {
+		read := func(ext string) []byte {
+			content, err := ioutil.ReadFile(filepath.Join(h.callsDir, id+ext))
+			require.NoError(h.t, err)
+			return content
+		}
+
+		c := call{Name: strings.TrimSpace(string(read(".name"))), Env: map[string]string{}, Stdin: string(read(".stdin"))}
+		if args := read(".args"); len(args) > 0 {
+			c.Args = strings.Split(strings.TrimSuffix(string(args), "\x00"), "\x00")
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(read(".env")))
+		for scanner.Scan() {
+			if split := strings.SplitN(scanner.Text(), "=", 2); len(split) == 2 {
+				c.Env[split[0]] = split[1]
+			}
+		}
+		require.NoError(h.t, scanner.Err())
+
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+// stubScript returns a shell script recording its invocation into STUB_CALLS_DIR and replaying the scripted responses.
+func (h *harness) stubScript(name string) string {
+	var cases strings.Builder
+	for _, r := range h.responses[name] {
+		fmt.Fprintf(&cases, "  %s)\n", strings.Replace(r.Pattern, " ", `\ `, -1))
+		if r.Script != "" {
+			fmt.Fprintf(&cases, "    %s\n", r.Script)
+		}
+		fmt.Fprintf(&cases, "    printf '%%s' %s\n", shellQuote(r.Stdout))
+		fmt.Fprintf(&cases, "    exit %d\n    ;;\n", r.ExitCode)
+	}
+
+	return fmt.Sprintf(`#!/bin/sh
+id="$STUB_CALLS_DIR/$(ls "$STUB_CALLS_DIR" | grep -c '\.name$' | awk '{ printf "%%06d", $1 }')"
+printf '%%s\0' "$@" > "$id.args"
+env > "$id.env"
+cat > "$id.stdin"
+echo %s > "$id.name"
+
+case "$*" in
+%s  *)
+    exit 0
+    ;;
+esac
+`, name, cases.String())
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
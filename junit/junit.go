@@ -0,0 +1,127 @@
+// Package junit converts Danger results to JUnit XML, as read by the Bitrise Test Reports add-on.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+)
+
+// TestSuites is the root element of the JUnit XML.
+type TestSuites struct {
+	XMLName    xml.Name    `xml:"testsuites"`
+	Name       string      `xml:"name,attr"`
+	Tests      int         `xml:"tests,attr"`
+	Failures   int         `xml:"failures,attr"`
+	Skipped    int         `xml:"skipped,attr"`
+	TestSuites []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite holds the test cases of a single Dangerfile.
+type TestSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	TestCases []TestCase `xml:"testcase"`
+	SystemOut string     `xml:"system-out,omitempty"`
+}
+
+// TestCase is a single Danger entry.
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+}
+
+// Failure marks a test case as failed.
+type Failure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// Skipped marks a test case as skipped.
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// Convert converts the results of a Danger run to JUnit test suites:
+// each Dangerfile becomes a suite, errors become failures, warnings become skipped test cases
+// and messages become passed test cases. Markdowns are added to the suite's system-out.
+func Convert(dangerResults results.Results) TestSuites {
+	suites := TestSuites{Name: "Danger"}
+
+	for _, dangerfile := range dangerResults.Dangerfiles {
+		suite := TestSuite{Name: dangerfile.Path}
+
+		for _, violation := range dangerfile.Errors {
+			testCase := newTestCase(dangerfile.Path, violation)
+			testCase.Failure = &Failure{Type: "error", Message: firstLine(violation.Message), Content: violation.Message}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Failures++
+		}
+		for _, violation := range dangerfile.Warnings {
+			testCase := newTestCase(dangerfile.Path, violation)
+			testCase.Skipped = &Skipped{Message: firstLine(violation.Message)}
+			testCase.SystemOut = violation.Message
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Skipped++
+		}
+		for _, violation := range dangerfile.Messages {
+			testCase := newTestCase(dangerfile.Path, violation)
+			testCase.SystemOut = violation.Message
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+
+		var markdowns []string
+		for _, markdown := range dangerfile.Markdowns {
+			markdowns = append(markdowns, markdown.Message)
+		}
+		suite.SystemOut = strings.Join(markdowns, "\n\n")
+
+		if len(suite.TestCases) == 0 {
+			// A Dangerfile without any entry passed, report it as a single passed test case.
+			suite.TestCases = append(suite.TestCases, TestCase{Name: "Dangerfile", ClassName: dangerfile.Path})
+		}
+		suite.Tests = len(suite.TestCases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.TestSuites = append(suites.TestSuites, suite)
+	}
+
+	return suites
+}
+
+// Encode returns the XML representation of the test suites.
+func (s TestSuites) Encode() ([]byte, error) {
+	data, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func newTestCase(dangerfile string, violation results.Violation) TestCase {
+	className := dangerfile
+	if violation.File != "" {
+		className = violation.File
+	}
+
+	name := firstLine(violation.Message)
+	if violation.File != "" && violation.Line > 0 {
+		name = fmt.Sprintf("%s:%d: %s", violation.File, violation.Line, name)
+	}
+
+	return TestCase{Name: name, ClassName: className}
+}
+
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(s, "\n", 2)[0])
+}
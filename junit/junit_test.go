@@ -0,0 +1,69 @@
+package junit
+
+import (
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	dangerResults := results.Results{Dangerfiles: []results.Dangerfile{
+		{
+			Path:      "/project/Dangerfile",
+			Errors:    []results.Violation{{Message: "Big PR\nSplit it up"}},
+			Warnings:  []results.Violation{{Message: "No tests", File: "main.go", Line: 12}},
+			Messages:  []results.Violation{{Message: "Thanks!"}},
+			Markdowns: []results.Violation{{Message: "## Summary"}},
+		},
+		{
+			Path: "/tmp/rules/Dangerfile",
+		},
+	}}
+
+	require.Equal(t, TestSuites{
+		Name:     "Danger",
+		Tests:    4,
+		Failures: 1,
+		Skipped:  1,
+		TestSuites: []TestSuite{
+			{
+				Name:     "/project/Dangerfile",
+				Tests:    3,
+				Failures: 1,
+				Skipped:  1,
+				TestCases: []TestCase{
+					{Name: "Big PR", ClassName: "/project/Dangerfile", Failure: &Failure{Type: "error", Message: "Big PR", Content: "Big PR\nSplit it up"}},
+					{Name: "main.go:12: No tests", ClassName: "main.go", Skipped: &Skipped{Message: "No tests"}, SystemOut: "No tests"},
+					{Name: "Thanks!", ClassName: "/project/Dangerfile", SystemOut: "Thanks!"},
+				},
+				SystemOut: "## Summary",
+			},
+			{
+				Name:      "/tmp/rules/Dangerfile",
+				Tests:     1,
+				TestCases: []TestCase{{Name: "Dangerfile", ClassName: "/tmp/rules/Dangerfile"}},
+			},
+		},
+	}, Convert(dangerResults))
+}
+
+func TestTestSuites_Encode(t *testing.T) {
+	suites := Convert(results.Results{Dangerfiles: []results.Dangerfile{
+		{
+			Path:   "Dangerfile",
+			Errors: []results.Violation{{Message: "Missing <CHANGELOG> entry"}},
+		},
+	}})
+
+	data, err := suites.Encode()
+	require.NoError(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="Danger" tests="1" failures="1" skipped="0">
+  <testsuite name="Dangerfile" tests="1" failures="1" skipped="0">
+    <testcase name="Missing &lt;CHANGELOG&gt; entry" classname="Dangerfile">
+      <failure type="error" message="Missing &lt;CHANGELOG&gt; entry">Missing &lt;CHANGELOG&gt; entry</failure>
+    </testcase>
+  </testsuite>
+</testsuites>`, string(data))
+}
@@ -0,0 +1,231 @@
+// Package network configures the HTTP proxy and the custom CA bundle of enterprise hosts,
+// for the step's own API calls and for the tools it runs.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// systemBundles are the PEM files of the system roots on Linux and macOS, the first existing one is used.
+var systemBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian, Ubuntu
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora, RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS, RHEL 7
+	"/etc/ssl/cert.pem",                                 // macOS, Alpine
+}
+
+// Config is the proxy and CA bundle configuration. The zero value uses the system defaults.
+type Config struct {
+	ProxyURL     string
+	NoProxy      string
+	CABundlePath string
+	// TrustStorePath is where WriteTrustStore writes the system roots and the CA bundle,
+	// for the tools replacing the system roots with the exported file.
+	TrustStorePath string
+}
+
+// Validate checks that the proxy URL is valid and the CA bundle exists and holds certificates.
+func (c Config) Validate() error {
+	if c.ProxyURL != "" {
+		if _, err := c.proxyURL(); err != nil {
+			return err
+		}
+	} else if c.NoProxy != "" {
+		return fmt.Errorf("no_proxy is set, but proxy_url is not set")
+	}
+
+	if c.CABundlePath != "" {
+		if _, err := c.caBundle(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProxyPassword returns the password in the proxy URL, to be masked in the logs.
+func (c Config) ProxyPassword() string {
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// Env returns the environment variables configuring the proxy and the CA bundle for Ruby (Bundler and Danger),
+// Node.js and git, in KEY=value form.
+func (c Config) Env() []string {
+	var env []string
+	if c.ProxyURL != "" {
+		for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+			env = append(env, key+"="+c.ProxyURL)
+		}
+	}
+	if c.NoProxy != "" {
+		env = append(env, "NO_PROXY="+c.NoProxy, "no_proxy="+c.NoProxy)
+	}
+	if c.CABundlePath != "" {
+		// Node.js adds the extra certificates to its own roots, Ruby, Bundler and git trust only the given file.
+		trustStore := c.CABundlePath
+		if c.TrustStorePath != "" {
+			trustStore = c.TrustStorePath
+		}
+		env = append(env, "SSL_CERT_FILE="+trustStore, "NODE_EXTRA_CA_CERTS="+c.CABundlePath)
+		env = append(env, "BUNDLE_SSL_CA_CERT="+trustStore, "GIT_SSL_CAINFO="+trustStore)
+	}
+	return env
+}
+
+// WriteTrustStore writes the system roots followed by the certificates of the CA bundle to TrustStorePath.
+// Without a system bundle found, only the CA bundle is written, and the public CAs are not trusted by Ruby and git.
+func (c Config) WriteTrustStore() error {
+	if c.CABundlePath == "" || c.TrustStorePath == "" {
+		return nil
+	}
+
+	custom, err := ioutil.ReadFile(c.CABundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to read ca_bundle_path (%s): %s", c.CABundlePath, err)
+	}
+
+	var content []byte
+	if pth := systemBundle(); pth != "" {
+		system, err := ioutil.ReadFile(pth)
+		if err != nil {
+			return fmt.Errorf("failed to read the system CA bundle (%s): %s", pth, err)
+		}
+		content = append(system, '\n')
+	} else {
+		log.Warnf("System CA bundle not found, Ruby and git trust only the certificates of %s", c.CABundlePath)
+	}
+	content = append(content, custom...)
+
+	if err := ioutil.WriteFile(c.TrustStorePath, content, 0600); err != nil {
+		return fmt.Errorf("failed to write the CA bundle: %s", err)
+	}
+	return nil
+}
+
+// systemBundle returns the file set in SSL_CERT_FILE or the first existing file of systemBundles, or an empty string.
+func systemBundle() string {
+	for _, pth := range append([]string{os.Getenv("SSL_CERT_FILE")}, systemBundles...) {
+		if pth == "" {
+			continue
+		}
+		if info, err := os.Stat(pth); err == nil && !info.IsDir() {
+			return pth
+		}
+	}
+	return ""
+}
+
+// HTTPClient returns a client with the given timeout, which connects through the proxy
+// and trusts the CA bundle on top of the system roots.
+func (c Config) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.ProxyURL != "" {
+		proxyURL, err := c.proxyURL()
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if c.bypassProxy(req.URL) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	if c.CABundlePath != "" {
+		pool, err := c.caBundle()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func (c Config) proxyURL() (*url.URL, error) {
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_url is not a valid URL: %s", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("proxy_url (%s) has to be an http:// or https:// URL with a host", u.Redacted())
+	}
+	return u, nil
+}
+
+// caBundle returns the system roots extended with the certificates of the CA bundle.
+func (c Config) caBundle() (*x509.CertPool, error) {
+	info, err := os.Stat(c.CABundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("ca_bundle_path (%s) does not exist", c.CABundlePath)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("ca_bundle_path (%s) is a directory, not a PEM file", c.CABundlePath)
+	}
+
+	content, err := ioutil.ReadFile(c.CABundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_bundle_path (%s): %s", c.CABundlePath, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("ca_bundle_path (%s) does not contain any PEM encoded certificate", c.CABundlePath)
+	}
+	return pool, nil
+}
+
+// bypassProxy returns whether the request goes to a host listed in no_proxy, following the curl conventions:
+// comma separated host names matching their subdomains too, optionally with a port, IP addresses, CIDR ranges and *.
+func (c Config) bypassProxy(u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.FieldsFunc(strings.ToLower(c.NoProxy), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		entryHost = strings.TrimPrefix(entryHost, "*")
+		entryHost = strings.TrimPrefix(entryHost, ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
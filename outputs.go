@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/testresultexport"
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/junit"
+	"github.com/bitrise-steplib/steps-danger/report"
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/bitrise-steplib/steps-danger/sarif"
+)
+
+// envmanExporter exports the step outputs with envman.
+type envmanExporter struct{}
+
+// ExportOutput implements outputExporter.
+func (envmanExporter) ExportOutput(key, value string) error {
+	return tools.ExportEnvironmentWithEnvman(key, value)
+}
+
+// exportResults exports the counts and the overall result of the Danger run.
+func (s dangerStep) exportResults(dangerResults results.Results) {
+	log.Infof("Exporting outputs")
+	for key, value := range map[string]string{
+		"DANGER_ERRORS_COUNT":   strconv.Itoa(dangerResults.ErrorsCount()),
+		"DANGER_WARNINGS_COUNT": strconv.Itoa(dangerResults.WarningsCount()),
+		"DANGER_MESSAGES_COUNT": strconv.Itoa(dangerResults.MessagesCount()),
+		"DANGER_RESULT":         string(dangerResults.Result()),
+	} {
+		if err := s.exporter.ExportOutput(key, value); err != nil {
+			log.Warnf("Failed to export %s: %s", key, err)
+			continue
+		}
+		log.Printf("%s: %s", key, value)
+	}
+}
+
+// exportReport writes the danger-results.json artifact to the deploy directory.
+func (s dangerStep) exportReport(dangerResults results.Results) {
+	if s.cfg.DeployDir == "" {
+		log.Warnf("Deploy directory is not set, %s is not written", report.FileName)
+		return
+	}
+
+	pth := filepath.Join(s.cfg.DeployDir, report.FileName)
+	if err := report.New(dangerResults, time.Now()).WriteFile(pth); err != nil {
+		log.Warnf("Failed to write %s: %s", pth, err)
+		return
+	}
+
+	if err := s.exporter.ExportOutput("DANGER_RESULTS_JSON_PATH", pth); err != nil {
+		log.Warnf("Failed to export DANGER_RESULTS_JSON_PATH: %s", err)
+		return
+	}
+	log.Printf("DANGER_RESULTS_JSON_PATH: %s", pth)
+}
+
+// exportJUnit writes the JUnit XML report into the test results directory, to be picked up by the Test Reports add-on.
+func (s dangerStep) exportJUnit(dangerResults results.Results) {
+	if s.cfg.TestResultDir == "" {
+		log.Warnf("Test result directory is not set, JUnit report is not exported")
+		return
+	}
+
+	data, err := junit.Convert(dangerResults).Encode()
+	if err != nil {
+		log.Warnf("Failed to generate JUnit report: %s", err)
+		return
+	}
+
+	tmpDir, err := ioutil.TempDir(s.tmpDir, "junit")
+	if err != nil {
+		log.Warnf("Failed to create temporary directory: %s", err)
+		return
+	}
+
+	pth := filepath.Join(tmpDir, "danger.xml")
+	if err := ioutil.WriteFile(pth, data, 0644); err != nil {
+		log.Warnf("Failed to write JUnit report: %s", err)
+		return
+	}
+
+	if err := testresultexport.NewExporter(s.cfg.TestResultDir).ExportTest("Danger", pth); err != nil {
+		log.Warnf("Failed to export JUnit report: %s", err)
+		return
+	}
+	log.Printf("JUnit report exported to: %s", filepath.Join(s.cfg.TestResultDir, "Danger"))
+}
+
+// exportSARIF writes the inline violations as SARIF to the configured path.
+func (s dangerStep) exportSARIF(dangerResults results.Results) {
+	pth := s.cfg.SARIFPath
+	if pth == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		log.Warnf("Failed to create directory for %s: %s", pth, err)
+		return
+	}
+
+	if err := sarif.Convert(dangerResults).WriteFile(pth); err != nil {
+		log.Warnf("Failed to write SARIF report: %s", err)
+		return
+	}
+
+	if err := s.exporter.ExportOutput("DANGER_SARIF_PATH", pth); err != nil {
+		log.Warnf("Failed to export DANGER_SARIF_PATH: %s", err)
+		return
+	}
+	log.Printf("DANGER_SARIF_PATH: %s", pth)
+}
@@ -0,0 +1,86 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/runner"
+)
+
+// CheckDangerfile verifies that the Dangerfile exists and Ruby can parse it, with `ruby -c` run in workDir.
+// Syntax errors are returned as *failure.Error, pointing at the file and line.
+func CheckDangerfile(r runner.Runner, workDir, pth string) error {
+	if info, err := os.Stat(pth); err != nil {
+		if os.IsNotExist(err) {
+			return failure.Errorf(failure.KindDangerfileException, "Dangerfile not found: %s", pth)
+		}
+		return fmt.Errorf("failed to check the Dangerfile: %s", err)
+	} else if info.IsDir() {
+		return failure.Errorf(failure.KindDangerfileException, "Dangerfile is a directory: %s", pth)
+	}
+
+	cmd := runner.New("ruby", "-c", pth)
+	cmd.Dir = workDir
+	log.Printf("$ %s", cmd)
+	out, err := r.Output(cmd)
+	if err == nil || errors.Is(err, runner.ErrDryRun) {
+		return nil
+	}
+
+	line, message, ok := parseSyntaxError(out, pth)
+	if !ok {
+		// Ruby may be missing or broken, installing the dependencies reports it in more detail.
+		log.Warnf("Could not check the syntax of the Dangerfile: %s", err)
+		if out != "" {
+			log.Warnf("%s", out)
+		}
+		return nil
+	}
+	return failure.Errorf(failure.KindDangerfileException, "syntax error in %s:%d: %s", pth, line, message)
+}
+
+// parseSyntaxError returns the first location `ruby -c` reported in the given file, for example:
+// /bitrise/src/Dangerfile:3: syntax error, unexpected end-of-input
+func parseSyntaxError(out, pth string) (int, string, bool) {
+	re := regexp.MustCompile(`(?m)` + regexp.QuoteMeta(pth) + `:(\d+):\s*(.*)$`)
+	match := re.FindStringSubmatch(out)
+	if match == nil {
+		return 0, "", false
+	}
+	line, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, "", false
+	}
+	return line, strings.TrimSpace(match[2]), true
+}
+
+// CheckLockfile verifies that the Gemfile.lock of the given Gemfile bundles the danger gem,
+// and returns its version. Without a Gemfile.lock the check is skipped, as Bundler resolves the gems on install.
+func CheckLockfile(gemfile string) (string, error) {
+	lockfile := gemfile + ".lock"
+	content, err := ioutil.ReadFile(lockfile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("No Gemfile.lock found, skipping the danger gem check")
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read %s: %s", lockfile, err)
+	}
+
+	version, err := gems.ParseVersionFromBundle("danger", string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %s", lockfile, err)
+	}
+	if !version.Found {
+		return "", failure.Errorf(failure.KindDependencyInstall, "the danger gem is missing from %s, add it to %s and run bundle install", lockfile, gemfile)
+	}
+	return version.Version, nil
+}
@@ -0,0 +1,144 @@
+package preflight
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/stretchr/testify/require"
+)
+
+const gemfileLock = `GEM
+  remote: https://rubygems.org/
+  specs:
+    claide-plugins (0.9.2)
+      cork
+      nap
+    danger (8.4.0)
+      claide-plugins (>= 0.9.2)
+
+DEPENDENCIES
+  danger
+
+BUNDLED WITH
+   2.2.24
+`
+
+func TestCheckDangerfile(t *testing.T) {
+	workDir := t.TempDir()
+	dangerfile := filepath.Join(workDir, "Dangerfile")
+	require.NoError(t, ioutil.WriteFile(dangerfile, []byte("if github.pr_title.empty?\n  fail('No title')\n"), 0600))
+
+	scenarios := []struct {
+		name         string
+		pth          string
+		result       runner.FakeResult
+		expectedPlan []string
+		expectedKind failure.Kind
+		expectedErr  string
+	}{
+		{
+			name:         "valid",
+			pth:          dangerfile,
+			result:       runner.FakeResult{Output: "Syntax OK"},
+			expectedPlan: []string{"ruby -c " + dangerfile},
+		},
+		{
+			name:         "missing",
+			pth:          filepath.Join(workDir, "ci", "Dangerfile"),
+			expectedKind: failure.KindDangerfileException,
+			expectedErr:  "Dangerfile not found: " + filepath.Join(workDir, "ci", "Dangerfile"),
+		},
+		{
+			name:         "directory",
+			pth:          workDir,
+			expectedKind: failure.KindDangerfileException,
+			expectedErr:  "Dangerfile is a directory: " + workDir,
+		},
+		{
+			name: "syntax error",
+			pth:  dangerfile,
+			result: runner.FakeResult{
+				Output: dangerfile + ":3: syntax error, unexpected end-of-input, expecting `end'",
+				Err:    errors.New("exit status 1"),
+			},
+			expectedPlan: []string{"ruby -c " + dangerfile},
+			expectedKind: failure.KindDangerfileException,
+			expectedErr:  "syntax error in " + dangerfile + ":3: syntax error, unexpected end-of-input, expecting `end'",
+		},
+		{
+			name: "syntax errors reported by Prism",
+			pth:  dangerfile,
+			result: runner.FakeResult{
+				Output: dangerfile + ": --> " + dangerfile + "\n" + dangerfile + ":1: syntax errors found (SyntaxError)\n> 1 | if github.pr_title.empty?",
+				Err:    errors.New("exit status 1"),
+			},
+			expectedPlan: []string{"ruby -c " + dangerfile},
+			expectedKind: failure.KindDangerfileException,
+			expectedErr:  "syntax error in " + dangerfile + ":1: syntax errors found (SyntaxError)",
+		},
+		{
+			name:         "ruby not found",
+			pth:          dangerfile,
+			result:       runner.FakeResult{Err: errors.New(`exec: "ruby": executable file not found in $PATH`)},
+			expectedPlan: []string{"ruby -c " + dangerfile},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{"ruby -c " + scenario.pth: scenario.result})
+			err := CheckDangerfile(r, workDir, scenario.pth)
+
+			require.Equal(t, scenario.expectedPlan, r.Plan())
+			for _, cmd := range r.Commands {
+				require.Equal(t, workDir, cmd.Dir)
+			}
+			if scenario.expectedKind == "" {
+				require.NoError(t, err)
+				return
+			}
+			var failureErr *failure.Error
+			require.True(t, errors.As(err, &failureErr), "%v", err)
+			require.Equal(t, scenario.expectedKind, failureErr.Kind)
+			require.EqualError(t, err, scenario.expectedErr)
+		})
+	}
+}
+
+func TestCheckLockfile(t *testing.T) {
+	scenarios := []struct {
+		name            string
+		lockfile        string
+		expectedVersion string
+		expectedKind    failure.Kind
+	}{
+		{name: "danger bundled", lockfile: gemfileLock, expectedVersion: "8.4.0"},
+		{name: "no Gemfile.lock"},
+		{name: "danger only a dependency", lockfile: strings.Replace(gemfileLock, "    danger (8.4.0)\n", "    danger-gitlab (8.0.0)\n      danger\n", 1), expectedKind: failure.KindDependencyInstall},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			gemfile := filepath.Join(t.TempDir(), "Gemfile")
+			if scenario.lockfile != "" {
+				require.NoError(t, ioutil.WriteFile(gemfile+".lock", []byte(scenario.lockfile), 0600))
+			}
+
+			version, err := CheckLockfile(gemfile)
+			if scenario.expectedKind == "" {
+				require.NoError(t, err)
+				require.Equal(t, scenario.expectedVersion, version)
+				return
+			}
+			var failureErr *failure.Error
+			require.True(t, errors.As(err, &failureErr), "%v", err)
+			require.Equal(t, scenario.expectedKind, failureErr.Kind)
+			require.EqualError(t, err, "the danger gem is missing from "+gemfile+".lock, add it to "+gemfile+" and run bundle install")
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package preflight checks the API tokens against the git providers and the project's Dangerfile and Gemfile.lock,
+// before the dependencies are installed.
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/provider"
+)
+
+// Scopes required to comment on pull requests with a classic GitHub personal access token.
+var githubCommentScopes = []string{"repo", "public_repo"}
+
+// Scope required to comment on merge requests with a GitLab access token.
+const gitlabCommentScope = "api"
+
+// Checker calls the git provider APIs with the configured tokens.
+type Checker struct {
+	client        *http.Client
+	scopesAdvised bool
+}
+
+// New returns a Checker.
+func New(client *http.Client) Checker {
+	return Checker{client: client}
+}
+
+// WithAdvisedScopes returns a Checker, which only warns about the missing comment scopes.
+// Least privilege tokens, like the restricted token of fork pull requests, are only required to be accepted.
+func (c Checker) WithAdvisedScopes() Checker {
+	c.scopesAdvised = true
+	return c
+}
+
+// Check verifies that the provider accepts the token and, where the provider reports it,
+// that the token has the scopes required to comment. Failures are returned as *failure.Error.
+func (c Checker) Check(p provider.Provider) error {
+	switch p.Key {
+	case "github":
+		return c.checkGitHub(p)
+	case "gitlab":
+		return c.checkGitLab(p)
+	default:
+		log.Warnf("Token check is not supported for %s", p.Name)
+		return nil
+	}
+}
+
+func (c Checker) checkGitHub(p provider.Provider) error {
+	resp, err := c.get(p, p.APIURL()+"/user")
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+
+	if err := checkStatus(p, resp); err != nil {
+		return err
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return failure.Errorf(failure.KindNetwork, "failed to parse the %s user response: %s", p.Name, err)
+	}
+	log.Donef("%s accepted the API token of %s", p.Name, user.Login)
+
+	// Only classic personal access tokens report their scopes, fine-grained and app tokens have permissions instead.
+	if _, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; !ok {
+		log.Printf("The token does not report its scopes, skipping the scope check")
+		return nil
+	}
+
+	scopes := parseScopes(resp.Header.Get("X-OAuth-Scopes"))
+	for _, required := range githubCommentScopes {
+		if contains(scopes, required) {
+			return nil
+		}
+	}
+	return c.missingScope(failure.Errorf(failure.KindAuthentication, "the %s API token is missing the %s scope required to comment on pull requests, it has: %s",
+		p.Name, strings.Join(githubCommentScopes, " or "), formatScopes(scopes)))
+}
+
+func (c Checker) checkGitLab(p provider.Provider) error {
+	resp, err := c.get(p, p.APIURL()+"/user")
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+
+	if err := checkStatus(p, resp); err != nil {
+		return err
+	}
+
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return failure.Errorf(failure.KindNetwork, "failed to parse the %s user response: %s", p.Name, err)
+	}
+	log.Donef("%s accepted the %s API token of %s", p.Name, gitlabTokenType(p), user.Username)
+
+	scopes, ok, err := c.gitlabScopes(p)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("The token does not report its scopes, skipping the scope check")
+		return nil
+	}
+	if !contains(scopes, gitlabCommentScope) {
+		return c.missingScope(failure.Errorf(failure.KindAuthentication, "the %s API token is missing the %s scope required to comment on merge requests, it has: %s",
+			p.Name, gitlabCommentScope, formatScopes(scopes)))
+	}
+	return nil
+}
+
+// missingScope returns the missing scope failure, or logs it as a warning if the scopes are only advised.
+func (c Checker) missingScope(err error) error {
+	if !c.scopesAdvised {
+		return err
+	}
+	log.Warnf("%s, Danger may not be able to comment", err)
+	return nil
+}
+
+// gitlabScopes returns the scopes of the token, and false if the GitLab instance does not report them.
+// OAuth tokens are described by the OAuth provider at the root of the instance, the access tokens by the API
+// (since GitLab 15.5).
+func (c Checker) gitlabScopes(p provider.Provider) ([]string, bool, error) {
+	url := p.APIURL() + "/personal_access_tokens/self"
+	if p.TokenType == provider.TokenTypeOAuth {
+		url = strings.TrimSuffix(p.APIURL(), "/api/v4") + "/oauth/token/info"
+	}
+
+	resp, err := c.get(p, url)
+	if err != nil {
+		return nil, false, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, nil
+	}
+
+	var token struct {
+		Scopes []string `json:"scopes"`
+		Scope  []string `json:"scope"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, false, failure.Errorf(failure.KindNetwork, "failed to parse the %s token response: %s", p.Name, err)
+	}
+	return append(token.Scopes, token.Scope...), true, nil
+}
+
+func (c Checker) get(p provider.Provider, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %s", p.Name, err)
+	}
+	req.Header.Set(p.AuthHeader())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, failure.Errorf(failure.KindNetwork, "failed to reach %s (%s): %s", p.Name, url, err)
+	}
+	return resp, nil
+}
+
+// checkStatus turns a non successful response into a failure. Rate limited and server side errors
+// are network failures, the rest is considered a rejected token.
+func checkStatus(p provider.Provider, resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(body))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.Header.Get("X-RateLimit-Remaining") == "0":
+		return failure.Errorf(failure.KindNetwork, "%s API rate limit exceeded (%s): %s", p.Name, resp.Status, message)
+	case resp.StatusCode >= 500:
+		return failure.Errorf(failure.KindNetwork, "%s API is not available (%s): %s", p.Name, resp.Status, message)
+	default:
+		return failure.Errorf(failure.KindAuthentication, "%s rejected the API token (%s): %s", p.Name, resp.Status, message)
+	}
+}
+
+func gitlabTokenType(p provider.Provider) provider.TokenType {
+	if p.TokenType == "" {
+		return provider.TokenTypePersonal
+	}
+	return p.TokenType
+}
+
+func parseScopes(header string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func formatScopes(scopes []string) string {
+	if len(scopes) == 0 {
+		return "no scopes"
+	}
+	return strings.Join(scopes, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func closeBody(body io.Closer) {
+	if err := body.Close(); err != nil {
+		log.Warnf("Failed to close response body: %s", err)
+	}
+}
@@ -0,0 +1,239 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/provider"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker_GitHub(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		status       int
+		headers      map[string]string
+		expectedKind failure.Kind
+		expectedErr  string
+	}{
+		{
+			name:    "classic token with repo scope",
+			status:  http.StatusOK,
+			headers: map[string]string{"X-OAuth-Scopes": "repo, read:org"},
+		},
+		{
+			name:    "classic token with public_repo scope",
+			status:  http.StatusOK,
+			headers: map[string]string{"X-OAuth-Scopes": "public_repo"},
+		},
+		{
+			name:   "fine-grained token",
+			status: http.StatusOK,
+		},
+		{
+			name:         "classic token without comment scope",
+			status:       http.StatusOK,
+			headers:      map[string]string{"X-OAuth-Scopes": "read:user, gist"},
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  "the GitHub API token is missing the repo or public_repo scope required to comment on pull requests, it has: read:user, gist",
+		},
+		{
+			name:         "classic token without scopes",
+			status:       http.StatusOK,
+			headers:      map[string]string{"X-OAuth-Scopes": ""},
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  "the GitHub API token is missing the repo or public_repo scope required to comment on pull requests, it has: no scopes",
+		},
+		{
+			name:         "bad credentials",
+			status:       http.StatusUnauthorized,
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  `GitHub rejected the API token (401 Unauthorized): {"message":"Bad credentials"}`,
+		},
+		{
+			name:         "rate limited",
+			status:       http.StatusForbidden,
+			headers:      map[string]string{"X-RateLimit-Remaining": "0"},
+			expectedKind: failure.KindNetwork,
+		},
+		{
+			name:         "server error",
+			status:       http.StatusBadGateway,
+			expectedKind: failure.KindNetwork,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/api/v3/user", r.URL.Path)
+				require.Equal(t, "token github-token", r.Header.Get("Authorization"))
+
+				for key, value := range scenario.headers {
+					w.Header()[key] = []string{value}
+				}
+				w.WriteHeader(scenario.status)
+				if scenario.status == http.StatusOK {
+					_, _ = fmt.Fprint(w, `{"login":"bitrise-bot"}`)
+				} else {
+					_, _ = fmt.Fprint(w, `{"message":"Bad credentials"}`)
+				}
+			}))
+			defer server.Close()
+
+			err := New(server.Client()).Check(provider.NewGitHub("github-token", "github.local", server.URL+"/api/v3"))
+			requireFailure(t, err, scenario.expectedKind, scenario.expectedErr)
+		})
+	}
+}
+
+func TestChecker_GitLab(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		tokenType    provider.TokenType
+		userStatus   int
+		tokenStatus  int
+		tokenScopes  string
+		expectedKind failure.Kind
+		expectedErr  string
+	}{
+		{
+			name:        "token with api scope",
+			userStatus:  http.StatusOK,
+			tokenStatus: http.StatusOK,
+			tokenScopes: `["api","read_user"]`,
+		},
+		{
+			name:         "token without api scope",
+			userStatus:   http.StatusOK,
+			tokenStatus:  http.StatusOK,
+			tokenScopes:  `["read_api"]`,
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  "the GitLab API token is missing the api scope required to comment on merge requests, it has: read_api",
+		},
+		{
+			name:        "token scopes not reported",
+			userStatus:  http.StatusOK,
+			tokenStatus: http.StatusNotFound,
+		},
+		{
+			name:        "group access token with api scope",
+			tokenType:   provider.TokenTypeGroup,
+			userStatus:  http.StatusOK,
+			tokenStatus: http.StatusOK,
+			tokenScopes: `["api"]`,
+		},
+		{
+			name:        "OAuth token with api scope",
+			tokenType:   provider.TokenTypeOAuth,
+			userStatus:  http.StatusOK,
+			tokenStatus: http.StatusOK,
+			tokenScopes: `["api","openid"]`,
+		},
+		{
+			name:         "OAuth token without api scope",
+			tokenType:    provider.TokenTypeOAuth,
+			userStatus:   http.StatusOK,
+			tokenStatus:  http.StatusOK,
+			tokenScopes:  `["read_user"]`,
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  "the GitLab API token is missing the api scope required to comment on merge requests, it has: read_user",
+		},
+		{
+			name:         "invalid token",
+			userStatus:   http.StatusUnauthorized,
+			expectedKind: failure.KindAuthentication,
+			expectedErr:  `GitLab rejected the API token (401 Unauthorized): {"message":"401 Unauthorized"}`,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if scenario.tokenType == provider.TokenTypeOAuth {
+					require.Equal(t, "Bearer gitlab-token", r.Header.Get("Authorization"))
+					require.Empty(t, r.Header.Get("PRIVATE-TOKEN"))
+				} else {
+					require.Equal(t, "gitlab-token", r.Header.Get("PRIVATE-TOKEN"))
+				}
+
+				switch r.URL.Path {
+				case "/api/v4/user":
+					w.WriteHeader(scenario.userStatus)
+					if scenario.userStatus == http.StatusOK {
+						_, _ = fmt.Fprint(w, `{"username":"bitrise-bot"}`)
+					} else {
+						_, _ = fmt.Fprint(w, `{"message":"401 Unauthorized"}`)
+					}
+				case "/api/v4/personal_access_tokens/self":
+					require.NotEqual(t, provider.TokenTypeOAuth, scenario.tokenType)
+					w.WriteHeader(scenario.tokenStatus)
+					_, _ = fmt.Fprintf(w, `{"scopes":%s}`, scenario.tokenScopes)
+				case "/oauth/token/info":
+					require.Equal(t, provider.TokenTypeOAuth, scenario.tokenType)
+					w.WriteHeader(scenario.tokenStatus)
+					_, _ = fmt.Fprintf(w, `{"scope":%s}`, scenario.tokenScopes)
+				default:
+					t.Errorf("unexpected request: %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			gitlab := provider.NewGitLab("gitlab-token", "gitlab.local", server.URL+"/api/v4")
+			gitlab.TokenType = scenario.tokenType
+			err := New(server.Client()).Check(gitlab)
+			requireFailure(t, err, scenario.expectedKind, scenario.expectedErr)
+		})
+	}
+}
+
+func TestChecker_WithAdvisedScopes(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		status       int
+		expectedKind failure.Kind
+	}{
+		{name: "token without comment scope", status: http.StatusOK},
+		{name: "bad credentials", status: http.StatusUnauthorized, expectedKind: failure.KindAuthentication},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-OAuth-Scopes", "read:user")
+				w.WriteHeader(scenario.status)
+				_, _ = fmt.Fprint(w, `{"login":"bitrise-bot"}`)
+			}))
+			defer server.Close()
+
+			err := New(server.Client()).WithAdvisedScopes().Check(provider.NewGitHub("restricted-token", "github.local", server.URL+"/api/v3"))
+			requireFailure(t, err, scenario.expectedKind, "")
+		})
+	}
+}
+
+func TestChecker_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := New(http.DefaultClient).Check(provider.NewGitHub("github-token", "github.local", server.URL))
+	requireFailure(t, err, failure.KindNetwork, "")
+}
+
+func requireFailure(t *testing.T, err error, expectedKind failure.Kind, expectedErr string) {
+	if expectedKind == "" {
+		require.NoError(t, err)
+		return
+	}
+
+	var failureErr *failure.Error
+	require.True(t, errors.As(err, &failureErr), "%v", err)
+	require.Equal(t, expectedKind, failureErr.Kind)
+	if expectedErr != "" {
+		require.EqualError(t, err, expectedErr)
+	}
+}
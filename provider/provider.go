@@ -0,0 +1,123 @@
+// Package provider describes the git providers Danger can comment on.
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/stepconf"
+)
+
+// TokenType is the kind of the API token, which determines how the step's own requests send the token to the provider.
+// Danger does not read it, its GitLab client picks the header from the token itself.
+type TokenType string
+
+// GitLab token types.
+const (
+	TokenTypePersonal TokenType = "personal"
+	TokenTypeProject  TokenType = "project"
+	TokenTypeGroup    TokenType = "group"
+	TokenTypeOAuth    TokenType = "oauth"
+)
+
+// Provider is a git provider configured for the Danger run.
+type Provider struct {
+	// Name is the display name of the provider.
+	Name string
+	// Key is the lower case identifier of the provider, used in the input and env var names.
+	Key string
+
+	Token      stepconf.Secret
+	TokenType  TokenType
+	Host       string
+	APIBaseURL string
+}
+
+// NewGitHub returns the GitHub provider.
+func NewGitHub(token stepconf.Secret, host, apiBaseURL string) Provider {
+	return Provider{Name: "GitHub", Key: "github", Token: token, Host: host, APIBaseURL: apiBaseURL}
+}
+
+// NewGitLab returns the GitLab provider.
+func NewGitLab(token stepconf.Secret, host, apiBaseURL string) Provider {
+	return Provider{Name: "GitLab", Key: "gitlab", Token: token, Host: host, APIBaseURL: apiBaseURL}
+}
+
+// IsConfigured returns whether an API token is set for the provider.
+func (p Provider) IsConfigured() bool {
+	return p.Token != ""
+}
+
+// IsEnterprise returns whether the provider is a self-hosted instance.
+func (p Provider) IsEnterprise() bool {
+	return p.Host != "" || p.APIBaseURL != ""
+}
+
+// APIURL returns the base URL of the provider's API: the configured one for self-hosted instances,
+// the public one otherwise.
+func (p Provider) APIURL() string {
+	if p.APIBaseURL != "" {
+		return strings.TrimSuffix(p.APIBaseURL, "/")
+	}
+	switch p.Key {
+	case "github":
+		return "https://api.github.com"
+	case "gitlab":
+		return "https://gitlab.com/api/v4"
+	default:
+		return ""
+	}
+}
+
+// AuthHeader returns the HTTP header the API token is sent in: OAuth tokens are bearer tokens,
+// GitLab personal, project and group access tokens use PRIVATE-TOKEN.
+func (p Provider) AuthHeader() (string, string) {
+	switch {
+	case p.Key == "github":
+		return "Authorization", "token " + string(p.Token)
+	case p.TokenType == TokenTypeOAuth:
+		return "Authorization", "Bearer " + string(p.Token)
+	default:
+		return "PRIVATE-TOKEN", string(p.Token)
+	}
+}
+
+// Validate checks that both or none of the host and API base URL are set.
+func (p Provider) Validate() error {
+	if p.IsEnterprise() && (p.Host == "" || p.APIBaseURL == "") {
+		return fmt.Errorf("if you want to use %s Enterprise you need to set both of the %s_host and the %s_api_base_url", p.Name, p.Key, p.Key)
+	}
+	return nil
+}
+
+// Envs returns the environment variables Danger reads the provider's configuration from.
+// Unset values are omitted.
+func (p Provider) Envs() map[string]string {
+	envs := map[string]string{}
+	for suffix, value := range map[string]string{
+		"API_TOKEN":    string(p.Token),
+		"HOST":         p.Host,
+		"API_BASE_URL": p.APIBaseURL,
+	} {
+		if value != "" {
+			envs[p.envPrefix()+suffix] = value
+		}
+	}
+	return envs
+}
+
+// TokenEnvKey returns the name of the environment variable Danger reads the provider's API token from.
+func (p Provider) TokenEnvKey() string {
+	return p.envPrefix() + "API_TOKEN"
+}
+
+func (p Provider) envPrefix() string {
+	switch p.Key {
+	case "github":
+		return "DANGER_GITHUB_"
+	case "gitlab":
+		return "DANGER_GITLAB_"
+	default:
+		return "DANGER_"
+	}
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_Validate(t *testing.T) {
+	require.NoError(t, NewGitHub("token", "", "").Validate())
+	require.NoError(t, NewGitHub("token", "git.corp.evilcorp.com", "https://git.corp.evilcorp.com/api/v3").Validate())
+	require.EqualError(t, NewGitHub("token", "git.corp.evilcorp.com", "").Validate(),
+		"if you want to use GitHub Enterprise you need to set both of the github_host and the github_api_base_url")
+	require.EqualError(t, NewGitLab("token", "", "https://git.corp.evilcorp.com/api/v4").Validate(),
+		"if you want to use GitLab Enterprise you need to set both of the gitlab_host and the gitlab_api_base_url")
+}
+
+func TestProvider_Envs(t *testing.T) {
+	require.Equal(t, map[string]string{
+		"DANGER_GITHUB_API_TOKEN": "token",
+	}, NewGitHub("token", "", "").Envs())
+
+	require.Equal(t, map[string]string{
+		"DANGER_GITLAB_API_TOKEN":    "token",
+		"DANGER_GITLAB_HOST":         "git.corp.evilcorp.com",
+		"DANGER_GITLAB_API_BASE_URL": "https://git.corp.evilcorp.com/api/v4",
+	}, NewGitLab("token", "git.corp.evilcorp.com", "https://git.corp.evilcorp.com/api/v4").Envs())
+
+	require.Empty(t, NewGitLab("", "", "").Envs())
+}
+
+func TestProvider_AuthHeader(t *testing.T) {
+	for _, scenario := range []struct {
+		provider      Provider
+		expectedKey   string
+		expectedValue string
+	}{
+		{NewGitHub("token", "", ""), "Authorization", "token token"},
+		{NewGitLab("token", "", ""), "PRIVATE-TOKEN", "token"},
+		{Provider{Key: "gitlab", Token: "token", TokenType: TokenTypeGroup}, "PRIVATE-TOKEN", "token"},
+		{Provider{Key: "gitlab", Token: "token", TokenType: TokenTypeOAuth}, "Authorization", "Bearer token"},
+	} {
+		key, value := scenario.provider.AuthHeader()
+		require.Equal(t, scenario.expectedKey, key, scenario.provider.TokenType)
+		require.Equal(t, scenario.expectedValue, value, scenario.provider.TokenType)
+	}
+}
+
+func TestProvider_APIURL(t *testing.T) {
+	require.Equal(t, "https://api.github.com", NewGitHub("token", "", "").APIURL())
+	require.Equal(t, "https://gitlab.com/api/v4", NewGitLab("token", "", "").APIURL())
+	require.Equal(t, "https://git.corp.evilcorp.com/api/v3", NewGitHub("token", "git.corp.evilcorp.com", "https://git.corp.evilcorp.com/api/v3/").APIURL())
+}
@@ -0,0 +1,56 @@
+// Package pullrequest detects pull requests opened from forks.
+package pullrequest
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ForkPolicy controls how Danger runs on pull requests opened from forks.
+type ForkPolicy string
+
+// Fork PR policies.
+const (
+	// ForkPolicyRun runs Danger on fork PRs the same way as on any other PR.
+	ForkPolicyRun ForkPolicy = "run"
+	// ForkPolicySkip does not run Danger on fork PRs.
+	ForkPolicySkip ForkPolicy = "skip"
+	// ForkPolicyRestrictedToken runs Danger on fork PRs with the restricted, read-only token.
+	ForkPolicyRestrictedToken ForkPolicy = "restricted_token"
+	// ForkPolicyBaseDangerfile runs the Dangerfile of the base branch on fork PRs, instead of the one in the PR.
+	ForkPolicyBaseDangerfile ForkPolicy = "base_dangerfile"
+)
+
+// IsFork returns whether the pull request was opened from a fork of the repository.
+// It returns false, if the build was not triggered by a pull request (prRepositoryURL is empty).
+func IsFork(repositoryURL, prRepositoryURL string) bool {
+	if prRepositoryURL == "" {
+		return false
+	}
+	return NormalizeURL(repositoryURL) != NormalizeURL(prRepositoryURL)
+}
+
+// NormalizeURL returns the host and path of a git repository URL in lower case, so that the https, ssh and
+// scp-like forms of the same repository are equal. For example all of the following result in github.com/org/repo:
+// https://github.com/org/repo.git, git@github.com:org/repo.git, ssh://git@github.com:22/org/repo
+func NormalizeURL(repositoryURL string) string {
+	s := strings.TrimSpace(repositoryURL)
+
+	if !strings.Contains(s, "://") {
+		if i := strings.Index(s, ":"); i > 0 && !strings.Contains(s[:i], "/") {
+			// scp-like syntax: [user@]host:path
+			s = "ssh://" + s[:i] + "/" + strings.TrimPrefix(s[i+1:], "/")
+		} else {
+			// host/path, as the repository URL is passed to older Danger versions
+			s = "https://" + s
+		}
+	}
+
+	host, pth := s, ""
+	if u, err := url.Parse(s); err == nil {
+		host, pth = u.Hostname(), u.Path
+	}
+
+	pth = strings.TrimSuffix(strings.Trim(pth, "/"), ".git")
+	return strings.ToLower(host + "/" + pth)
+}
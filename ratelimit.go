@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/config"
+	"github.com/bitrise-steplib/steps-danger/provider"
+	"github.com/bitrise-steplib/steps-danger/ratelimit"
+)
+
+// checkRateLimit waits for the GitHub API rate limit to reset, if fewer calls remain than the threshold,
+// and exports the outcome. It returns false, if the limit resets too late and Danger has to be skipped.
+func (s dangerStep) checkRateLimit(cfg config.Config, client *http.Client) bool {
+	github := provider.NewGitHub(cfg.GithubAPIToken, cfg.GithubHost, cfg.GithubAPIBaseURL)
+	if !github.IsConfigured() {
+		log.Warnf("The rate limit check is only supported for GitHub, skipping it")
+		return true
+	}
+	log.Infof("Checking the %s API rate limit", github.Name)
+	defer fmt.Println()
+
+	status, err := ratelimit.New(client).Get(github)
+	if err != nil {
+		log.Warnf("Failed to check the rate limit, running Danger regardless: %s", err)
+		s.exportRateLimitOutcome(ratelimit.OutcomeUnknown)
+		return true
+	}
+
+	maxWait := time.Duration(cfg.RateLimitMaxWait) * time.Second
+	outcome, wait := ratelimit.Decide(status, cfg.RateLimitThreshold, maxWait, time.Now())
+	switch outcome {
+	case ratelimit.OutcomeUnlimited:
+		log.Printf("Rate limiting is not enabled on %s", github.APIURL())
+	case ratelimit.OutcomeOK:
+		log.Donef("%d of %d API calls remaining", status.Remaining, status.Limit)
+	case ratelimit.OutcomeWaited:
+		log.Warnf("%d of %d API calls remaining, fewer than rate_limit_threshold (%d), waiting %s for the reset at %s",
+			status.Remaining, status.Limit, cfg.RateLimitThreshold, wait, status.Reset.UTC().Format(time.RFC3339))
+		s.sleep(wait)
+	case ratelimit.OutcomeSkipped:
+		log.Warnf("%d of %d API calls remaining, fewer than rate_limit_threshold (%d), and the limit resets in %s, later than rate_limit_max_wait (%s)",
+			status.Remaining, status.Limit, cfg.RateLimitThreshold, wait, maxWait)
+		log.Warnf("Skipping Danger")
+	}
+
+	s.exportRateLimitOutcome(outcome)
+	return outcome != ratelimit.OutcomeSkipped
+}
+
+func (s dangerStep) exportRateLimitOutcome(outcome ratelimit.Outcome) {
+	if err := s.exporter.ExportOutput("DANGER_RATE_LIMIT_OUTCOME", string(outcome)); err != nil {
+		log.Warnf("Failed to export DANGER_RATE_LIMIT_OUTCOME: %s", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package ratelimit checks the GitHub API rate limit before running Danger, which can make many API calls on large pull requests.
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/provider"
+)
+
+// Outcome is the result of the rate limit check, exported as DANGER_RATE_LIMIT_OUTCOME.
+type Outcome string
+
+// Rate limit check outcomes.
+const (
+	// OutcomeOK means enough calls remained to run Danger.
+	OutcomeOK Outcome = "ok"
+	// OutcomeWaited means Danger ran after waiting for the rate limit to reset.
+	OutcomeWaited Outcome = "waited"
+	// OutcomeSkipped means Danger was skipped, as the rate limit resets later than the step may wait.
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeUnlimited means the GitHub Enterprise instance has rate limiting disabled.
+	OutcomeUnlimited Outcome = "unlimited"
+	// OutcomeUnknown means the rate limit could not be checked, Danger ran regardless.
+	OutcomeUnknown Outcome = "unknown"
+)
+
+// Status is the core API rate limit of the token.
+type Status struct {
+	// Enabled is false, if the GitHub Enterprise instance has rate limiting disabled.
+	Enabled   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Client queries the rate limit endpoint of the GitHub API.
+type Client struct {
+	client *http.Client
+}
+
+// New returns a Client.
+func New(client *http.Client) Client {
+	return Client{client: client}
+}
+
+// Get returns the core API rate limit of the provider's token. Querying it does not count against the limit.
+func (c Client) Get(p provider.Provider) (Status, error) {
+	url := p.APIURL() + "/rate_limit"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to create %s request: %s", p.Name, err)
+	}
+	req.Header.Set(p.AuthHeader())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to reach %s (%s): %s", p.Name, url, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read the %s rate limit response: %s", p.Name, err)
+	}
+
+	// GitHub Enterprise Server responds with 404 if rate limiting is disabled.
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(string(body), "Rate limiting is not enabled") {
+		return Status{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Status{}, fmt.Errorf("%s rate limit request failed (%s): %s", p.Name, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var rateLimit struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &rateLimit); err != nil {
+		return Status{}, fmt.Errorf("failed to parse the %s rate limit response: %s", p.Name, err)
+	}
+
+	core := rateLimit.Resources.Core
+	return Status{Enabled: true, Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
+
+// Decide returns whether Danger can run with the remaining calls, and if not, how long to wait for the reset.
+// The step waits at most maxWait, Danger is skipped if the limit resets later.
+func Decide(status Status, threshold int, maxWait time.Duration, now time.Time) (Outcome, time.Duration) {
+	if !status.Enabled {
+		return OutcomeUnlimited, 0
+	}
+	if status.Remaining >= threshold {
+		return OutcomeOK, 0
+	}
+
+	// A second of margin, as the reset time is truncated to seconds.
+	wait := status.Reset.Sub(now) + time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxWait {
+		return OutcomeSkipped, wait
+	}
+	return OutcomeWaited, wait
+}
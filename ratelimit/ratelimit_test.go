@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/provider"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Get(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		status         int
+		body           string
+		expectedStatus Status
+		expectedErr    string
+	}{
+		{
+			name:           "rate limited",
+			status:         http.StatusOK,
+			body:           `{"resources":{"core":{"limit":5000,"remaining":42,"reset":1627819200,"used":4958}},"rate":{"limit":5000}}`,
+			expectedStatus: Status{Enabled: true, Limit: 5000, Remaining: 42, Reset: time.Unix(1627819200, 0)},
+		},
+		{
+			name:   "rate limiting disabled",
+			status: http.StatusNotFound,
+			body:   `{"message":"Rate limiting is not enabled.","documentation_url":"https://docs.github.com/enterprise/rest"}`,
+		},
+		{
+			name:        "bad credentials",
+			status:      http.StatusUnauthorized,
+			body:        `{"message":"Bad credentials"}`,
+			expectedErr: `GitHub rate limit request failed (401 Unauthorized): {"message":"Bad credentials"}`,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/api/v3/rate_limit", r.URL.Path)
+				require.Equal(t, "token github-token", r.Header.Get("Authorization"))
+				w.WriteHeader(scenario.status)
+				_, _ = fmt.Fprint(w, scenario.body)
+			}))
+			defer server.Close()
+
+			status, err := New(server.Client()).Get(provider.NewGitHub("github-token", "github.local", server.URL+"/api/v3"))
+			if scenario.expectedErr != "" {
+				require.EqualError(t, err, scenario.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, scenario.expectedStatus, status)
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		name            string
+		status          Status
+		expectedOutcome Outcome
+		expectedWait    time.Duration
+	}{
+		{
+			name:            "rate limiting disabled",
+			status:          Status{},
+			expectedOutcome: OutcomeUnlimited,
+		},
+		{
+			name:            "enough calls remaining",
+			status:          Status{Enabled: true, Limit: 5000, Remaining: 100, Reset: now.Add(time.Hour)},
+			expectedOutcome: OutcomeOK,
+		},
+		{
+			name:            "resets within the max wait",
+			status:          Status{Enabled: true, Limit: 5000, Remaining: 99, Reset: now.Add(2 * time.Minute)},
+			expectedOutcome: OutcomeWaited,
+			expectedWait:    2*time.Minute + time.Second,
+		},
+		{
+			name:            "already reset",
+			status:          Status{Enabled: true, Limit: 5000, Remaining: 0, Reset: now.Add(-time.Minute)},
+			expectedOutcome: OutcomeWaited,
+		},
+		{
+			name:            "resets after the max wait",
+			status:          Status{Enabled: true, Limit: 5000, Remaining: 0, Reset: now.Add(30 * time.Minute)},
+			expectedOutcome: OutcomeSkipped,
+			expectedWait:    30*time.Minute + time.Second,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			outcome, wait := Decide(scenario.status, 100, 5*time.Minute, now)
+			require.Equal(t, scenario.expectedOutcome, outcome)
+			require.Equal(t, scenario.expectedWait, wait)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package redact masks secrets in the output of the executed commands.
+package redact
+
+import (
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the secrets in the output.
+const Mask = "[REDACTED]"
+
+// minSecretLength is the length under which a secret (or an encoded form of it) is not masked,
+// as masking it would garble the output without protecting anything.
+const minSecretLength = 4
+
+// Redactor masks the configured secrets, in their raw, URL-encoded and base64 encoded forms.
+type Redactor struct {
+	mu       sync.RWMutex
+	patterns []string
+}
+
+// New returns a Redactor for the given secrets. Empty secrets are ignored.
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add masks the given secrets too, for secrets which are only known during the run. Empty secrets are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unique := map[string]bool{}
+	for _, pattern := range r.patterns {
+		unique[pattern] = true
+	}
+	for _, secret := range secrets {
+		for _, pattern := range variants(secret) {
+			if len(pattern) >= minSecretLength {
+				unique[pattern] = true
+			}
+		}
+	}
+
+	var patterns []string
+	for pattern := range unique {
+		patterns = append(patterns, pattern)
+	}
+	// Longer patterns first, so that a secret containing another one is masked as a whole.
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	r.patterns = patterns
+}
+
+// String masks the secrets in s.
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, pattern := range r.patterns {
+		s = strings.Replace(s, pattern, Mask, -1)
+	}
+	return s
+}
+
+// Writer returns a Writer masking the secrets before writing to w.
+func (r *Redactor) Writer(w io.Writer) *Writer {
+	return &Writer{redactor: r, w: w}
+}
+
+// Writer masks secrets in a stream, even if a secret is split across Write calls.
+// The end of the stream, which might be the beginning of a secret, is held back until Flush is called.
+type Writer struct {
+	mu       sync.Mutex
+	redactor *Redactor
+	w        io.Writer
+	pending  string
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	buf := w.redactor.String(w.pending + string(p))
+	hold := w.redactor.partialSuffix(buf)
+	w.pending = buf[len(buf)-hold:]
+
+	if _, err := io.WriteString(w.w, buf[:len(buf)-hold]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the held back end of the stream.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pending := w.pending
+	w.pending = ""
+	_, err := io.WriteString(w.w, pending)
+	return err
+}
+
+// partialSuffix returns the length of the longest suffix of s, which is the beginning of a secret.
+func (r *Redactor) partialSuffix(s string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	longest := 0
+	for _, pattern := range r.patterns {
+		for n := len(pattern) - 1; n > longest; n-- {
+			if n <= len(s) && strings.HasSuffix(s, pattern[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// variants returns the forms of the secret, which might show up in the output.
+func variants(secret string) []string {
+	if secret == "" {
+		return nil
+	}
+
+	forms := []string{secret, url.QueryEscape(secret), url.PathEscape(secret)}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		forms = append(forms, encoding.EncodeToString([]byte(secret)))
+		forms = append(forms, base64Variants(encoding, secret)...)
+	}
+	return forms
+}
+
+// base64Variants returns the parts of the base64 encoded form of the secret, which do not depend on the surrounding
+// bytes, for each of the three possible alignments. This way the secret is found even if it was encoded together
+// with other data, for example in a basic auth header.
+func base64Variants(encoding *base64.Encoding, secret string) []string {
+	var forms []string
+	for offset := 0; offset < 3; offset++ {
+		data := append(make([]byte, offset), secret...)
+		encoded := encoding.WithPadding(base64.NoPadding).EncodeToString(data)
+
+		start := (offset*8 + 5) / 6
+		end := len(data) * 8 / 6
+		if start < end {
+			forms = append(forms, encoded[start:end])
+		}
+	}
+	return forms
+}
@@ -0,0 +1,132 @@
+// Package report defines the schema of the danger-results.json artifact.
+//
+// The schema is versioned: fields may be added without changing SchemaVersion,
+// but renaming, removing or changing the meaning of a field requires a new version.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+)
+
+// SchemaVersion is the version of the schema written by this package.
+const SchemaVersion = 1
+
+// FileName is the name of the artifact in the deploy directory.
+const FileName = "danger-results.json"
+
+// Kind is the kind of an entry reported by Danger.
+type Kind string
+
+// Entry kinds.
+const (
+	KindError    Kind = "error"
+	KindWarning  Kind = "warning"
+	KindMessage  Kind = "message"
+	KindMarkdown Kind = "markdown"
+)
+
+// Entry is a single error, warning, message or markdown reported by a Dangerfile.
+type Entry struct {
+	Kind    Kind   `json:"kind"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Dangerfile holds the entries reported by a single Dangerfile.
+type Dangerfile struct {
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+}
+
+// Summary holds the number of entries by kind.
+type Summary struct {
+	Errors    int `json:"errors"`
+	Warnings  int `json:"warnings"`
+	Messages  int `json:"messages"`
+	Markdowns int `json:"markdowns"`
+}
+
+// Report is the root object of the artifact.
+type Report struct {
+	SchemaVersion int            `json:"schema_version"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Result        results.Result `json:"result"`
+	Summary       Summary        `json:"summary"`
+	Dangerfiles   []Dangerfile   `json:"dangerfiles"`
+}
+
+// New converts the results of a Danger run to a Report.
+func New(dangerResults results.Results, generatedAt time.Time) Report {
+	r := Report{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   generatedAt.UTC(),
+		Result:        dangerResults.Result(),
+		Dangerfiles:   []Dangerfile{},
+	}
+
+	for _, dangerfile := range dangerResults.Dangerfiles {
+		d := Dangerfile{Path: dangerfile.Path, Entries: []Entry{}}
+		for _, group := range []struct {
+			kind       Kind
+			violations []results.Violation
+			count      *int
+		}{
+			{KindError, dangerfile.Errors, &r.Summary.Errors},
+			{KindWarning, dangerfile.Warnings, &r.Summary.Warnings},
+			{KindMessage, dangerfile.Messages, &r.Summary.Messages},
+			{KindMarkdown, dangerfile.Markdowns, &r.Summary.Markdowns},
+		} {
+			for _, violation := range group.violations {
+				d.Entries = append(d.Entries, Entry{
+					Kind:    group.kind,
+					Message: violation.Message,
+					File:    violation.File,
+					Line:    violation.Line,
+				})
+			}
+			*group.count += len(group.violations)
+		}
+		r.Dangerfiles = append(r.Dangerfiles, d)
+	}
+
+	return r
+}
+
+// Encode returns the JSON representation of the Report.
+func (r Report) Encode() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Decode parses a Report, written by this or an earlier schema version.
+func Decode(data []byte) (Report, error) {
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return Report{}, fmt.Errorf("failed to parse report: %s", err)
+	}
+	if version.SchemaVersion < 1 || version.SchemaVersion > SchemaVersion {
+		return Report{}, fmt.Errorf("unsupported report schema version: %d", version.SchemaVersion)
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Report{}, fmt.Errorf("failed to parse report: %s", err)
+	}
+	return r, nil
+}
+
+// WriteFile writes the JSON representation of the Report to pth.
+func (r Report) WriteFile(pth string) error {
+	data, err := r.Encode()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pth, data, 0644)
+}
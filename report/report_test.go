@@ -0,0 +1,101 @@
+package report
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/stretchr/testify/require"
+)
+
+var generatedAt = time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+
+func TestNew(t *testing.T) {
+	dangerResults := results.Results{Dangerfiles: []results.Dangerfile{
+		{
+			Path:      "/project/Dangerfile",
+			Errors:    []results.Violation{{Message: "Big PR"}},
+			Warnings:  []results.Violation{{Message: "No tests", File: "main.go", Line: 12}},
+			Markdowns: []results.Violation{{Message: "## Summary"}},
+		},
+		{
+			Path: "/tmp/rules/Dangerfile",
+		},
+	}}
+
+	require.Equal(t, Report{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   generatedAt,
+		Result:        results.ResultFail,
+		Summary:       Summary{Errors: 1, Warnings: 1, Markdowns: 1},
+		Dangerfiles: []Dangerfile{
+			{
+				Path: "/project/Dangerfile",
+				Entries: []Entry{
+					{Kind: KindError, Message: "Big PR"},
+					{Kind: KindWarning, Message: "No tests", File: "main.go", Line: 12},
+					{Kind: KindMarkdown, Message: "## Summary"},
+				},
+			},
+			{
+				Path:    "/tmp/rules/Dangerfile",
+				Entries: []Entry{},
+			},
+		},
+	}, New(dangerResults, generatedAt))
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := New(results.Results{Dangerfiles: []results.Dangerfile{
+		{
+			Path:     "/project/Dangerfile",
+			Warnings: []results.Violation{{Message: "No tests", File: "main.go", Line: 12}},
+			Messages: []results.Violation{{Message: "Thanks!"}},
+		},
+	}}, generatedAt)
+
+	data, err := r.Encode()
+	require.NoError(t, err)
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, r, decoded)
+
+	pth := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, r.WriteFile(pth))
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("stable field names", func(t *testing.T) {
+		data := `{
+  "schema_version": 1,
+  "generated_at": "2021-06-01T12:30:00Z",
+  "result": "warn",
+  "summary": {"errors": 0, "warnings": 1, "messages": 0, "markdowns": 0},
+  "dangerfiles": [{"path": "/project/Dangerfile", "entries": [{"kind": "warning", "message": "No tests", "file": "main.go", "line": 12}]}]
+}`
+
+		r, err := Decode([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, Report{
+			SchemaVersion: 1,
+			GeneratedAt:   generatedAt,
+			Result:        results.ResultWarn,
+			Summary:       Summary{Warnings: 1},
+			Dangerfiles: []Dangerfile{
+				{Path: "/project/Dangerfile", Entries: []Entry{{Kind: KindWarning, Message: "No tests", File: "main.go", Line: 12}}},
+			},
+		}, r)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := Decode([]byte(`{"schema_version": 2}`))
+		require.EqualError(t, err, "unsupported report schema version: 2")
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		_, err := Decode([]byte(`{"result": "pass"}`))
+		require.EqualError(t, err, "unsupported report schema version: 0")
+	})
+}
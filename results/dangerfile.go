@@ -0,0 +1,48 @@
+package results
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// wrapperTemplate imports the given Dangerfiles one by one and dumps the entries each of them
+// added to Danger's messaging plugin as JSON, matching the Results structure.
+const wrapperTemplate = `# Generated by the Bitrise Danger step, do not edit.
+require "json"
+
+bitrise_dangerfiles = [%s]
+bitrise_kinds = %%w[errors warnings messages markdowns]
+
+bitrise_results = bitrise_dangerfiles.map do |bitrise_dangerfile|
+  bitrise_before = bitrise_kinds.map { |kind| [kind, messaging.instance_variable_get("@#{kind}").count] }.to_h
+
+  danger.import_dangerfile(path: bitrise_dangerfile)
+
+  bitrise_entry = { "dangerfile" => bitrise_dangerfile }
+  bitrise_kinds.each do |kind|
+    bitrise_entry[kind] = messaging.instance_variable_get("@#{kind}").drop(bitrise_before[kind]).map do |violation|
+      { "message" => violation.message, "file" => violation.file, "line" => violation.line }
+    end
+  end
+  bitrise_entry
+end
+
+File.write(%s, JSON.generate({ "dangerfiles" => bitrise_results }))
+`
+
+// WrapperDangerfile returns the content of a Dangerfile, which evaluates the given Dangerfiles
+// and writes the reported entries to resultsPth.
+func WrapperDangerfile(dangerfiles []string, resultsPth string) string {
+	var quoted []string
+	for _, dangerfile := range dangerfiles {
+		quoted = append(quoted, rubyString(dangerfile))
+	}
+	return fmt.Sprintf(wrapperTemplate, strings.Join(quoted, ", "), rubyString(resultsPth))
+}
+
+// rubyString quotes s as a Ruby string literal. Go's double quoted escaping is a valid subset of Ruby's,
+// except for the interpolation marker.
+func rubyString(s string) string {
+	return strings.Replace(strconv.Quote(s), "#{", `\#{`, -1)
+}
@@ -0,0 +1,30 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapperDangerfile(t *testing.T) {
+	wrapper := WrapperDangerfile([]string{"/project/Dangerfile", "/tmp/rules/Dangerfile"}, "/tmp/results.json")
+
+	require.Contains(t, wrapper, `bitrise_dangerfiles = ["/project/Dangerfile", "/tmp/rules/Dangerfile"]`)
+	require.Contains(t, wrapper, `bitrise_kinds = %w[errors warnings messages markdowns]`)
+	require.Contains(t, wrapper, `File.write("/tmp/results.json", JSON.generate({ "dangerfiles" => bitrise_results }))`)
+}
+
+func Test_rubyString(t *testing.T) {
+	scenarios := []struct {
+		input    string
+		expected string
+	}{
+		{"/project/Dangerfile", `"/project/Dangerfile"`},
+		{`/with "quotes"`, `"/with \"quotes\""`},
+		{"/with/#{interpolation}", `"/with/\#{interpolation}"`},
+	}
+
+	for _, scenario := range scenarios {
+		require.Equal(t, scenario.expected, rubyString(scenario.input))
+	}
+}
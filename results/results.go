@@ -0,0 +1,91 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Result is the overall outcome of a Danger run.
+type Result string
+
+// Possible overall outcomes.
+const (
+	ResultPass Result = "pass"
+	ResultWarn Result = "warn"
+	ResultFail Result = "fail"
+)
+
+// Violation is a single error, warning, message or markdown entry reported by Danger.
+type Violation struct {
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Dangerfile holds the entries reported while evaluating a single Dangerfile.
+type Dangerfile struct {
+	Path      string      `json:"dangerfile"`
+	Errors    []Violation `json:"errors"`
+	Warnings  []Violation `json:"warnings"`
+	Messages  []Violation `json:"messages"`
+	Markdowns []Violation `json:"markdowns"`
+}
+
+// Results is the collection of the entries reported by a Danger run, grouped by Dangerfile.
+type Results struct {
+	Dangerfiles []Dangerfile `json:"dangerfiles"`
+}
+
+// ReadFile reads the results dumped by the wrapper Dangerfile.
+func ReadFile(pth string) (Results, error) {
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return Results{}, err
+	}
+
+	var results Results
+	if err := json.Unmarshal(content, &results); err != nil {
+		return Results{}, fmt.Errorf("failed to parse danger results (%s): %s", pth, err)
+	}
+	return results, nil
+}
+
+// ErrorsCount returns the number of errors reported across all Dangerfiles.
+func (r Results) ErrorsCount() int {
+	count := 0
+	for _, dangerfile := range r.Dangerfiles {
+		count += len(dangerfile.Errors)
+	}
+	return count
+}
+
+// WarningsCount returns the number of warnings reported across all Dangerfiles.
+func (r Results) WarningsCount() int {
+	count := 0
+	for _, dangerfile := range r.Dangerfiles {
+		count += len(dangerfile.Warnings)
+	}
+	return count
+}
+
+// MessagesCount returns the number of messages reported across all Dangerfiles.
+func (r Results) MessagesCount() int {
+	count := 0
+	for _, dangerfile := range r.Dangerfiles {
+		count += len(dangerfile.Messages)
+	}
+	return count
+}
+
+// Result returns fail if any error, warn if any warning was reported, pass otherwise.
+func (r Results) Result() Result {
+	switch {
+	case r.ErrorsCount() > 0:
+		return ResultFail
+	case r.WarningsCount() > 0:
+		return ResultWarn
+	default:
+		return ResultPass
+	}
+}
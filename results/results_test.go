@@ -0,0 +1,78 @@
+package results
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "results.json")
+	content := `{"dangerfiles":[{"dangerfile":"/project/Dangerfile","errors":[{"message":"Big PR","file":null,"line":null}],"warnings":[{"message":"No tests","file":"main.go","line":12}],"messages":[],"markdowns":[]}]}`
+	require.NoError(t, ioutil.WriteFile(pth, []byte(content), 0600))
+
+	results, err := ReadFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, Results{
+		Dangerfiles: []Dangerfile{
+			{
+				Path:      "/project/Dangerfile",
+				Errors:    []Violation{{Message: "Big PR"}},
+				Warnings:  []Violation{{Message: "No tests", File: "main.go", Line: 12}},
+				Messages:  []Violation{},
+				Markdowns: []Violation{},
+			},
+		},
+	}, results)
+}
+
+func TestResults_Result(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		results  Results
+		expected Result
+	}{
+		{
+			name:     "no dangerfiles",
+			results:  Results{},
+			expected: ResultPass,
+		},
+		{
+			name:     "messages only",
+			results:  Results{Dangerfiles: []Dangerfile{{Messages: []Violation{{Message: "Hi"}}}}},
+			expected: ResultPass,
+		},
+		{
+			name:     "warnings",
+			results:  Results{Dangerfiles: []Dangerfile{{Warnings: []Violation{{Message: "Hmm"}}}}},
+			expected: ResultWarn,
+		},
+		{
+			name: "errors in any dangerfile",
+			results: Results{Dangerfiles: []Dangerfile{
+				{Warnings: []Violation{{Message: "Hmm"}}},
+				{Errors: []Violation{{Message: "No"}}},
+			}},
+			expected: ResultFail,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			require.Equal(t, scenario.expected, scenario.results.Result())
+		})
+	}
+}
+
+func TestResults_Counts(t *testing.T) {
+	results := Results{Dangerfiles: []Dangerfile{
+		{Errors: []Violation{{}}, Warnings: []Violation{{}, {}}, Messages: []Violation{{}}},
+		{Errors: []Violation{{}}, Messages: []Violation{{}, {}}},
+	}}
+
+	require.Equal(t, 2, results.ErrorsCount())
+	require.Equal(t, 2, results.WarningsCount())
+	require.Equal(t, 3, results.MessagesCount())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/rules"
+)
+
+// rulesDangerfiles returns the Dangerfiles to evaluate: the project's Dangerfile, followed by the one
+// compiled from the rules input if set. The project's Dangerfile is optional, if the rules input is set.
+func (s dangerStep) rulesDangerfiles(content, dangerfile string) ([]string, error) {
+	if content == "" {
+		return []string{dangerfile}, nil
+	}
+
+	log.Infof("Compiling the rules")
+
+	r, err := rules.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	pth := filepath.Join(s.tmpDir, "rules", "Dangerfile")
+	if err := os.MkdirAll(filepath.Dir(pth), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the rules directory: %s", err)
+	}
+	if err := fileutil.WriteStringToFile(pth, r.Dangerfile()); err != nil {
+		return nil, fmt.Errorf("failed to write the rules Dangerfile: %s", err)
+	}
+	log.Printf("Rules compiled into %s", pth)
+
+	var dangerfiles []string
+	if _, err := os.Stat(dangerfile); err == nil {
+		dangerfiles = append(dangerfiles, dangerfile)
+	} else if os.IsNotExist(err) {
+		log.Printf("No Dangerfile found at %s, running the rules only", dangerfile)
+	} else {
+		return nil, fmt.Errorf("failed to check the Dangerfile: %s", err)
+	}
+	fmt.Println()
+	return append(dangerfiles, pth), nil
+}
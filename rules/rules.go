@@ -0,0 +1,299 @@
+// Package rules compiles the declarative checks of the rules input into a Dangerfile.
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the Danger entry a failed check reports.
+type Severity string
+
+// Severities.
+const (
+	SeverityWarn Severity = "warn"
+	SeverityFail Severity = "fail"
+)
+
+// Focused test functions of RSpec, Jasmine and Jest, reported by the focused_tests check.
+var defaultFocusedFunctions = []string{"fdescribe", "fcontext", "fit"}
+
+// PRSize limits the number of changed lines.
+type PRSize struct {
+	MaxLines int      `yaml:"max_lines"`
+	Severity Severity `yaml:"severity"`
+}
+
+// Changelog requires the changelog file to be modified or added.
+type Changelog struct {
+	File     string   `yaml:"file"`
+	Severity Severity `yaml:"severity"`
+}
+
+// FocusedTests reports added lines calling a focused test function.
+type FocusedTests struct {
+	Functions []string `yaml:"functions"`
+	Severity  Severity `yaml:"severity"`
+}
+
+// Description requires a pull request description of at least MinLength characters.
+type Description struct {
+	MinLength int      `yaml:"min_length"`
+	Severity  Severity `yaml:"severity"`
+}
+
+// Labels requires a label on the pull request, one of OneOf if set.
+type Labels struct {
+	OneOf    []string `yaml:"one_of"`
+	Severity Severity `yaml:"severity"`
+}
+
+// Rules are the checks of the rules input, a nil check is disabled.
+type Rules struct {
+	PRSize       *PRSize       `yaml:"pr_size"`
+	Changelog    *Changelog    `yaml:"changelog"`
+	FocusedTests *FocusedTests `yaml:"focused_tests"`
+	Description  *Description  `yaml:"pr_description"`
+	Labels       *Labels       `yaml:"labels"`
+}
+
+// Parse parses the YAML rules, fills in the defaults and validates them.
+// A check listed without options, like `changelog:`, is enabled with its defaults.
+// Unknown keys are rejected, so a misspelled check does not get disabled silently.
+func Parse(content string) (Rules, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return Rules{}, fmt.Errorf("invalid rules: %s", err)
+	}
+	var r Rules
+	if len(doc.Content) > 0 {
+		enableBareChecks(&doc)
+		normalized, err := yaml.Marshal(&doc)
+		if err != nil {
+			return Rules{}, fmt.Errorf("invalid rules: %s", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(normalized))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&r); err != nil && err != io.EOF {
+			return Rules{}, fmt.Errorf("invalid rules: %s", err)
+		}
+	}
+
+	r.setDefaults()
+	if err := r.validate(); err != nil {
+		return Rules{}, fmt.Errorf("invalid rules: %s", err)
+	}
+	return r, nil
+}
+
+// enableBareChecks replaces the null values of the top level keys with empty mappings.
+func enableBareChecks(doc *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	mapping := doc.Content[0]
+	for i := 1; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Tag == "!!null" {
+			mapping.Content[i] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+	}
+}
+
+// IsEmpty returns whether none of the checks are enabled.
+func (r Rules) IsEmpty() bool {
+	return r.PRSize == nil && r.Changelog == nil && r.FocusedTests == nil && r.Description == nil && r.Labels == nil
+}
+
+func (r *Rules) setDefaults() {
+	if r.PRSize != nil && r.PRSize.Severity == "" {
+		r.PRSize.Severity = SeverityWarn
+	}
+	if r.Changelog != nil {
+		if r.Changelog.File == "" {
+			r.Changelog.File = "CHANGELOG.md"
+		}
+		if r.Changelog.Severity == "" {
+			r.Changelog.Severity = SeverityWarn
+		}
+	}
+	if r.FocusedTests != nil {
+		if len(r.FocusedTests.Functions) == 0 {
+			r.FocusedTests.Functions = defaultFocusedFunctions
+		}
+		if r.FocusedTests.Severity == "" {
+			r.FocusedTests.Severity = SeverityFail
+		}
+	}
+	if r.Description != nil {
+		if r.Description.MinLength == 0 {
+			r.Description.MinLength = 1
+		}
+		if r.Description.Severity == "" {
+			r.Description.Severity = SeverityWarn
+		}
+	}
+	if r.Labels != nil && r.Labels.Severity == "" {
+		r.Labels.Severity = SeverityFail
+	}
+}
+
+func (r Rules) validate() error {
+	if r.IsEmpty() {
+		return errors.New("none of the checks are enabled, set at least one of pr_size, changelog, focused_tests, pr_description and labels")
+	}
+
+	severities := map[string]Severity{}
+	if r.PRSize != nil {
+		if r.PRSize.MaxLines <= 0 {
+			return errors.New("pr_size.max_lines has to be a positive number")
+		}
+		severities["pr_size"] = r.PRSize.Severity
+	}
+	if r.Changelog != nil {
+		severities["changelog"] = r.Changelog.Severity
+	}
+	if r.FocusedTests != nil {
+		for _, function := range r.FocusedTests.Functions {
+			if !isIdentifier(function) {
+				return fmt.Errorf("focused_tests.functions: %q is not a function name", function)
+			}
+		}
+		severities["focused_tests"] = r.FocusedTests.Severity
+	}
+	if r.Description != nil {
+		if r.Description.MinLength < 0 {
+			return errors.New("pr_description.min_length can not be negative")
+		}
+		severities["pr_description"] = r.Description.Severity
+	}
+	if r.Labels != nil {
+		severities["labels"] = r.Labels.Severity
+	}
+
+	for check, severity := range severities {
+		if severity != SeverityWarn && severity != SeverityFail {
+			return fmt.Errorf("%s.severity has to be %s or %s, not %s", check, SeverityWarn, SeverityFail, severity)
+		}
+	}
+	return nil
+}
+
+// isIdentifier returns whether s can be embedded in the generated regular expression as is.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+const header = `# Generated by the Bitrise Danger step from the rules input, do not edit.
+`
+
+const pullRequestTemplate = `
+bitrise_gitlab = danger.scm_provider == :gitlab
+bitrise_pr_body = bitrise_gitlab ? gitlab.mr_body : github.pr_body
+bitrise_pr_labels = bitrise_gitlab ? gitlab.mr_labels : github.pr_labels
+`
+
+const prSizeTemplate = `
+# pr_size
+if git.lines_of_code > %[1]d
+  %[2]s("This pull request changes #{git.lines_of_code} lines, more than %[1]d. Consider splitting it up.")
+end
+`
+
+const changelogTemplate = `
+# changelog
+bitrise_changelog = %[1]s
+unless (git.modified_files + git.added_files).include?(bitrise_changelog)
+  %[2]s("Please add an entry to #{bitrise_changelog}.")
+end
+`
+
+const focusedTestsTemplate = `
+# focused_tests
+bitrise_focused_test = /^\+\s*(%[1]s)\s*\(?\s*["']/
+(git.modified_files + git.added_files).uniq.each do |file|
+  diff = git.diff_for_file(file)
+  next if diff.nil?
+
+  diff.patch.each_line do |line|
+    match = line.match(bitrise_focused_test)
+    next if match.nil?
+
+    %[2]s("Focused test (#{match[1]}) added in #{file}, remove it before merging.")
+  end
+end
+`
+
+const descriptionTemplate = `
+# pr_description
+if bitrise_pr_body.to_s.strip.length < %[1]d
+  %[2]s("Please describe the pull request in at least %[1]d characters.")
+end
+`
+
+const anyLabelTemplate = `
+# labels
+if bitrise_pr_labels.empty?
+  %[1]s("Please add a label to the pull request.")
+end
+`
+
+const oneOfLabelsTemplate = `
+# labels
+bitrise_required_labels = [%[1]s]
+if (bitrise_pr_labels & bitrise_required_labels).empty?
+  %[2]s("Please add one of the labels to the pull request: #{bitrise_required_labels.join(", ")}.")
+end
+`
+
+// Dangerfile returns the content of a Dangerfile running the enabled checks.
+func (r Rules) Dangerfile() string {
+	var b strings.Builder
+	b.WriteString(header)
+
+	if r.Description != nil || r.Labels != nil {
+		b.WriteString(pullRequestTemplate)
+	}
+	if r.PRSize != nil {
+		fmt.Fprintf(&b, prSizeTemplate, r.PRSize.MaxLines, r.PRSize.Severity)
+	}
+	if r.Changelog != nil {
+		fmt.Fprintf(&b, changelogTemplate, rubyString(r.Changelog.File), r.Changelog.Severity)
+	}
+	if r.FocusedTests != nil {
+		fmt.Fprintf(&b, focusedTestsTemplate, strings.Join(r.FocusedTests.Functions, "|"), r.FocusedTests.Severity)
+	}
+	if r.Description != nil {
+		fmt.Fprintf(&b, descriptionTemplate, r.Description.MinLength, r.Description.Severity)
+	}
+	if r.Labels != nil {
+		if len(r.Labels.OneOf) == 0 {
+			fmt.Fprintf(&b, anyLabelTemplate, r.Labels.Severity)
+		} else {
+			var labels []string
+			for _, label := range r.Labels.OneOf {
+				labels = append(labels, rubyString(label))
+			}
+			fmt.Fprintf(&b, oneOfLabelsTemplate, strings.Join(labels, ", "), r.Labels.Severity)
+		}
+	}
+	return b.String()
+}
+
+// rubyString quotes s as a single quoted Ruby string literal, which is never interpolated.
+func rubyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,138 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	scenarios := []struct {
+		name        string
+		content     string
+		expected    Rules
+		expectedErr string
+	}{
+		{
+			name: "every check with options",
+			content: `pr_size:
+  max_lines: 500
+  severity: fail
+changelog:
+  file: docs/CHANGELOG.md
+focused_tests:
+  functions: [fit, xit]
+pr_description:
+  min_length: 20
+labels:
+  one_of: [bug, feature]
+  severity: warn
+`,
+			expected: Rules{
+				PRSize:       &PRSize{MaxLines: 500, Severity: SeverityFail},
+				Changelog:    &Changelog{File: "docs/CHANGELOG.md", Severity: SeverityWarn},
+				FocusedTests: &FocusedTests{Functions: []string{"fit", "xit"}, Severity: SeverityFail},
+				Description:  &Description{MinLength: 20, Severity: SeverityWarn},
+				Labels:       &Labels{OneOf: []string{"bug", "feature"}, Severity: SeverityWarn},
+			},
+		},
+		{
+			name:    "bare checks use the defaults",
+			content: "changelog:\nfocused_tests:\npr_description:\nlabels:\n",
+			expected: Rules{
+				Changelog:    &Changelog{File: "CHANGELOG.md", Severity: SeverityWarn},
+				FocusedTests: &FocusedTests{Functions: []string{"fdescribe", "fcontext", "fit"}, Severity: SeverityFail},
+				Description:  &Description{MinLength: 1, Severity: SeverityWarn},
+				Labels:       &Labels{Severity: SeverityFail},
+			},
+		},
+		{
+			name:        "empty",
+			content:     "",
+			expectedErr: "invalid rules: none of the checks are enabled, set at least one of pr_size, changelog, focused_tests, pr_description and labels",
+		},
+		{
+			name:        "misspelled check",
+			content:     "changelog:\npr_sise:\n  max_lines: 500\n",
+			expectedErr: "invalid rules: yaml: unmarshal errors:\n  line 2: field pr_sise not found in type rules.Rules",
+		},
+		{
+			name:        "pr_size without max_lines",
+			content:     "pr_size:\n",
+			expectedErr: "invalid rules: pr_size.max_lines has to be a positive number",
+		},
+		{
+			name:        "unknown severity",
+			content:     "labels:\n  severity: error\n",
+			expectedErr: "invalid rules: labels.severity has to be warn or fail, not error",
+		},
+		{
+			name:        "focused test function with a regexp",
+			content:     "focused_tests:\n  functions: ['f.*']\n",
+			expectedErr: `invalid rules: focused_tests.functions: "f.*" is not a function name`,
+		},
+		{
+			name:        "not a mapping",
+			content:     "- changelog\n",
+			expectedErr: "invalid rules: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!seq into rules.Rules",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			r, err := Parse(scenario.content)
+			if scenario.expectedErr != "" {
+				require.EqualError(t, err, scenario.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, scenario.expected, r)
+		})
+	}
+}
+
+func TestRules_Dangerfile(t *testing.T) {
+	r, err := Parse(`pr_size:
+  max_lines: 500
+changelog:
+  file: "it's/CHANGELOG.md"
+focused_tests:
+labels:
+  one_of: [bug, feature]
+`)
+	require.NoError(t, err)
+
+	dangerfile := r.Dangerfile()
+	require.Contains(t, dangerfile, "# Generated by the Bitrise Danger step from the rules input, do not edit.\n")
+	require.Contains(t, dangerfile, "bitrise_pr_labels = bitrise_gitlab ? gitlab.mr_labels : github.pr_labels\n")
+	require.Contains(t, dangerfile, `if git.lines_of_code > 500
+  warn("This pull request changes #{git.lines_of_code} lines, more than 500. Consider splitting it up.")
+end`)
+	require.Contains(t, dangerfile, `bitrise_changelog = 'it\'s/CHANGELOG.md'`)
+	require.Contains(t, dangerfile, `bitrise_focused_test = /^\+\s*(fdescribe|fcontext|fit)\s*\(?\s*["']/`)
+	require.Contains(t, dangerfile, `    fail("Focused test (#{match[1]}) added in #{file}, remove it before merging.")`)
+	require.Contains(t, dangerfile, `bitrise_required_labels = ['bug', 'feature']`)
+	require.NotContains(t, dangerfile, "# pr_description")
+}
+
+func TestRules_Dangerfile_WithoutPullRequestChecks(t *testing.T) {
+	r, err := Parse("changelog:\n")
+	require.NoError(t, err)
+	require.NotContains(t, r.Dangerfile(), "bitrise_pr_body")
+}
+
+func Test_rubyString(t *testing.T) {
+	scenarios := []struct {
+		input    string
+		expected string
+	}{
+		{"CHANGELOG.md", `'CHANGELOG.md'`},
+		{`it's`, `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+		{"#{interpolation}", `'#{interpolation}'`},
+	}
+
+	for _, scenario := range scenarios {
+		require.Equal(t, scenario.expected, rubyString(scenario.input))
+	}
+}
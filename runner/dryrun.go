@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrDryRun is the error of the commands, which were not executed for their output in dry run mode.
+var ErrDryRun = errors.New("not executed in dry run")
+
+// DryRunner records the commands and prints them, instead of executing them.
+// Only the read-only probes are executed by the underlying Runner, for their output.
+type DryRunner struct {
+	runner Runner
+	probes []string
+	// Commands holds the commands, which were not executed.
+	Commands []Command
+}
+
+// NewDryRunner returns a DryRunner, which executes the given read-only probes, like `gem list`, for their output.
+func NewDryRunner(r Runner, probes ...string) *DryRunner {
+	return &DryRunner{runner: r, probes: probes}
+}
+
+// Run implements Runner. The command and its environment are written to the command's Stdout.
+func (r *DryRunner) Run(cmd Command) error {
+	r.Commands = append(r.Commands, cmd)
+
+	var stdout io.Writer = os.Stdout
+	if cmd.Stdout != nil {
+		stdout = cmd.Stdout
+	}
+	if _, err := fmt.Fprintf(stdout, "Dry run, not executed: %s\n", cmd); err != nil {
+		return err
+	}
+	for _, env := range cmd.Env {
+		if _, err := fmt.Fprintf(stdout, "  %s\n", env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Output implements Runner. The read-only probes are executed, every other command is recorded
+// and returns ErrDryRun, as if it failed.
+func (r *DryRunner) Output(cmd Command) (string, error) {
+	for _, probe := range r.probes {
+		if cmd.String() == probe {
+			return r.runner.Output(cmd)
+		}
+	}
+
+	r.Commands = append(r.Commands, cmd)
+	fmt.Printf("Dry run, not executed: %s\n", cmd)
+	return "", ErrDryRun
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/redact"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunner(t *testing.T) {
+	fake := NewFakeRunner(map[string]FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+	dry := NewDryRunner(fake, "gem list")
+	r := NewRedactingRunner(dry, redact.New("s3cr3t-token"))
+
+	out, err := r.Output(New("gem", "list"))
+	require.NoError(t, err)
+	require.Equal(t, "bundler (2.2.24)", out)
+
+	out, err = r.Output(New("bundle", "check"))
+	require.True(t, errors.Is(err, ErrDryRun), "%v", err)
+	require.Empty(t, out)
+
+	var stdout bytes.Buffer
+	err = r.Run(Command{
+		Name:   "bundle",
+		Args:   []string{"exec", "danger"},
+		Env:    []string{"BUNDLE_PATH=/cache/bundle", "DANGER_GITHUB_API_TOKEN=s3cr3t-token"},
+		Stdout: &stdout,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Dry run, not executed: bundle exec danger\n  BUNDLE_PATH=/cache/bundle\n  DANGER_GITHUB_API_TOKEN=[REDACTED]\n", stdout.String())
+
+	require.Equal(t, []string{"gem list"}, fake.Plan())
+	require.Len(t, dry.Commands, 2)
+	require.Equal(t, "bundle check", dry.Commands[0].String())
+	require.Equal(t, "bundle exec danger", dry.Commands[1].String())
+}
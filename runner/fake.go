@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FakeResult is the scripted result of a command executed by a FakeRunner.
+type FakeResult struct {
+	Output string
+	Err    error
+}
+
+// FakeRunner records the executed commands instead of executing them, and returns scripted results.
+type FakeRunner struct {
+	// Results holds the scripted results by the printable form of the command.
+	// Commands without a scripted result succeed without output.
+	Results  map[string]FakeResult
+	Commands []Command
+}
+
+// NewFakeRunner returns a FakeRunner with the given scripted results.
+func NewFakeRunner(results map[string]FakeResult) *FakeRunner {
+	return &FakeRunner{Results: results}
+}
+
+// Run implements Runner. The scripted output is written to the command's Stdout.
+func (r *FakeRunner) Run(cmd Command) error {
+	result := r.record(cmd)
+	if cmd.Stdout != nil && result.Output != "" {
+		if _, err := io.WriteString(cmd.Stdout, result.Output); err != nil {
+			return err
+		}
+	}
+	return result.Err
+}
+
+// Output implements Runner.
+func (r *FakeRunner) Output(cmd Command) (string, error) {
+	result := r.record(cmd)
+	return strings.TrimSpace(result.Output), result.Err
+}
+
+// Plan returns the printable form of the recorded commands.
+func (r *FakeRunner) Plan() []string {
+	var plan []string
+	for _, cmd := range r.Commands {
+		plan = append(plan, cmd.String())
+	}
+	return plan
+}
+
+// Command returns the first recorded command with the given printable form.
+func (r *FakeRunner) Command(printable string) (Command, error) {
+	for _, cmd := range r.Commands {
+		if cmd.String() == printable {
+			return cmd, nil
+		}
+	}
+	return Command{}, fmt.Errorf("command not executed: %s", printable)
+}
+
+func (r *FakeRunner) record(cmd Command) FakeResult {
+	r.Commands = append(r.Commands, cmd)
+	return r.Results[cmd.String()]
+}
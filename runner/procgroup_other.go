@@ -0,0 +1,14 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package runner
+
+import "os/exec"
+
+// setProcessGroup is a no-op, process groups are only supported on macOS and Linux.
+func setProcessGroup(*exec.Cmd) {}
+
+// killProcessGroup kills the command only, process groups are only supported on macOS and Linux.
+func killProcessGroup(c *exec.Cmd) {
+	_ = c.Process.Kill()
+}
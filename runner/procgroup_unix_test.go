@@ -0,0 +1,52 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRunner_Timeout(t *testing.T) {
+	r := NewCommandRunner()
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+
+	// The child process keeps running after the shell is killed, unless the whole process group is killed.
+	var stdout bytes.Buffer
+	start := time.Now()
+	err := r.Run(Command{
+		Name:    "sh",
+		Args:    []string{"-c", "sleep 30 & echo $! > " + pidFile + "; echo started; wait"},
+		Stdout:  &stdout,
+		Timeout: 200 * time.Millisecond,
+	})
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+
+	var timeoutErr *TimeoutError
+	require.True(t, errors.As(err, &timeoutErr), "%v", err)
+	require.Equal(t, 200*time.Millisecond, timeoutErr.Timeout)
+	require.EqualError(t, err, "sh -c 'sleep 30 & echo $! > "+pidFile+"; echo started; wait' timed out after 200ms")
+	require.Equal(t, "started\n", stdout.String())
+
+	content, err := ioutil.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 5*time.Second, 50*time.Millisecond, "child process %d is still running", pid)
+
+	out, err := r.Output(Command{Name: "sh", Args: []string{"-c", "echo $RUNNER_TEST_ENV"}, Env: []string{"RUNNER_TEST_ENV=value"}, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	require.Equal(t, "value", out)
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"os"
+
+	"github.com/bitrise-steplib/steps-danger/redact"
+)
+
+// RedactingRunner masks secrets in the output of every command executed by the underlying Runner.
+type RedactingRunner struct {
+	runner   Runner
+	redactor *redact.Redactor
+}
+
+// NewRedactingRunner returns a RedactingRunner.
+func NewRedactingRunner(r Runner, redactor *redact.Redactor) RedactingRunner {
+	return RedactingRunner{runner: r, redactor: redactor}
+}
+
+// Run implements Runner.
+func (r RedactingRunner) Run(cmd Command) error {
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	stdout, stderr := r.redactor.Writer(cmd.Stdout), r.redactor.Writer(cmd.Stderr)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err := r.runner.Run(cmd)
+
+	for _, w := range []*redact.Writer{stdout, stderr} {
+		if flushErr := w.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// Output implements Runner.
+func (r RedactingRunner) Output(cmd Command) (string, error) {
+	out, err := r.runner.Output(cmd)
+	return r.redactor.String(out), err
+}
@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/redact"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactingRunner(t *testing.T) {
+	r := NewRedactingRunner(NewCommandRunner(), redact.New("s3cr3t-token"))
+
+	var stdout, stderr bytes.Buffer
+	err := r.Run(Command{
+		Name:   "sh",
+		Args:   []string{"-c", "printf 'token: s3cr3t-'; printf 'token\n'; echo s3cr3t-token >&2; printf s3cr3t"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "token: [REDACTED]\ns3cr3t", stdout.String())
+	require.Equal(t, "[REDACTED]\n", stderr.String())
+
+	out, err := r.Output(New("echo", "s3cr3t-token"))
+	require.NoError(t, err)
+	require.Equal(t, "[REDACTED]", out)
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/failure"
+)
+
+// RetryPolicy controls how often and when a failed command is executed again.
+type RetryPolicy struct {
+	// Attempts is the number of executions of a failing command, a single one if less than 2.
+	Attempts int
+	// Backoff is the wait before the second attempt, doubled before every further attempt.
+	Backoff time.Duration
+	// IsTransient decides, based on the output of the failed command, whether it is worth retrying.
+	IsTransient func(output string) bool
+}
+
+// RetryingRunner executes the commands failing with a transient error again, as the RetryPolicy allows.
+// Commands executed for their output are not retried, neither are the ones killed on their Timeout.
+type RetryingRunner struct {
+	runner Runner
+	policy RetryPolicy
+	sleep  func(time.Duration)
+}
+
+// NewRetryingRunner returns a RetryingRunner.
+func NewRetryingRunner(r Runner, policy RetryPolicy) RetryingRunner {
+	return RetryingRunner{runner: r, policy: policy, sleep: time.Sleep}
+}
+
+// Run implements Runner.
+func (r RetryingRunner) Run(cmd Command) error {
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	start := time.Now()
+	backoff := r.policy.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		output := failure.NewOutputTail(64 * 1024)
+		c := cmd
+		c.Stdout = io.MultiWriter(cmd.Stdout, output)
+		c.Stderr = io.MultiWriter(cmd.Stderr, output)
+		// The retries share the timeout of the command. A zero Timeout would not limit the attempt at all.
+		if cmd.Timeout > 0 && attempt > 1 {
+			c.Timeout = cmd.Timeout - time.Since(start)
+			if c.Timeout <= 0 {
+				log.Warnf("%s failed with a transient error, not retrying as its timeout passed", cmd)
+				return err
+			}
+		}
+
+		err = r.runner.Run(c)
+		if err == nil || attempt >= r.policy.Attempts || !r.policy.IsTransient(output.String()) {
+			return err
+		}
+		var timeoutErr *TimeoutError
+		if errors.As(err, &timeoutErr) {
+			return err
+		}
+		if cmd.Timeout > 0 && time.Since(start)+backoff >= cmd.Timeout {
+			log.Warnf("%s failed with a transient error, not retrying as it would exceed its timeout", cmd)
+			return err
+		}
+
+		log.Warnf("%s failed with a transient error (attempt %d/%d), retrying in %s", cmd, attempt, r.policy.Attempts, backoff)
+		r.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Output implements Runner.
+func (r RetryingRunner) Output(cmd Command) (string, error) {
+	return r.runner.Output(cmd)
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryingRunner(t *testing.T) {
+	// flakyScript fails with the given output, until it ran the given number of times.
+	flakyScript := func(counter string, failures int, output string) Command {
+		return New("sh", "-c", `n=$(cat `+counter+` 2>/dev/null || echo 0); n=$((n+1)); echo $n > `+counter+`
+if [ $n -le `+strconv.Itoa(failures)+` ]; then echo "`+output+`"; exit 1; fi
+echo installed`)
+	}
+
+	scenarios := []struct {
+		name           string
+		failures       int
+		output         string
+		expectedErr    bool
+		expectedSleeps []time.Duration
+		expectedStdout string
+	}{
+		{
+			name:           "transient failures",
+			failures:       2,
+			output:         "Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503",
+			expectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+			expectedStdout: strings.Repeat("Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503\n", 2) + "installed\n",
+		},
+		{
+			name:           "attempts exhausted",
+			failures:       3,
+			output:         "Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503",
+			expectedErr:    true,
+			expectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+			expectedStdout: strings.Repeat("Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503\n", 3),
+		},
+		{
+			name:           "permanent failure",
+			failures:       1,
+			output:         "[!] Invalid Dangerfile file: undefined local variable or method gitt",
+			expectedErr:    true,
+			expectedStdout: "[!] Invalid Dangerfile file: undefined local variable or method gitt\n",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			r := NewRetryingRunner(NewCommandRunner(), RetryPolicy{Attempts: 3, Backoff: time.Second, IsTransient: failure.IsTransient})
+			r.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			var stdout bytes.Buffer
+			cmd := flakyScript(filepath.Join(t.TempDir(), "counter"), scenario.failures, scenario.output)
+			cmd.Stdout = &stdout
+			err := r.Run(cmd)
+
+			require.Equal(t, scenario.expectedErr, err != nil, "%v", err)
+			require.Equal(t, scenario.expectedSleeps, sleeps)
+			require.Equal(t, scenario.expectedStdout, stdout.String())
+		})
+	}
+}
+
+func TestRetryingRunner_NotRetried(t *testing.T) {
+	fake := NewFakeRunner(map[string]FakeResult{
+		"bundle install": {Output: "Gem::RemoteFetcher::FetchError", Err: &TimeoutError{Command: "bundle install", Timeout: time.Minute}},
+		"gem list":       {Output: "Gem::RemoteFetcher::FetchError", Err: errors.New("exit status 1")},
+	})
+	r := NewRetryingRunner(fake, RetryPolicy{Attempts: 3, IsTransient: failure.IsTransient})
+	r.sleep = func(time.Duration) { t.Fatal("unexpected retry") }
+
+	require.Error(t, r.Run(New("bundle", "install")))
+	_, err := r.Output(New("gem", "list"))
+	require.Error(t, err)
+	require.Equal(t, []string{"bundle install", "gem list"}, fake.Plan())
+}
+
+func TestRetryingRunner_TimeoutPassed(t *testing.T) {
+	fake := NewFakeRunner(map[string]FakeResult{
+		"bundle install": {Output: "Gem::RemoteFetcher::FetchError", Err: errors.New("exit status 5")},
+	})
+	r := NewRetryingRunner(fake, RetryPolicy{Attempts: 3, IsTransient: failure.IsTransient})
+	// The backoff is zero, but the wait still uses up the timeout.
+	r.sleep = func(time.Duration) { time.Sleep(20 * time.Millisecond) }
+
+	cmd := New("bundle", "install")
+	cmd.Timeout = 10 * time.Millisecond
+	require.EqualError(t, r.Run(cmd), "exit status 5")
+	require.Len(t, fake.Commands, 1)
+}
@@ -0,0 +1,217 @@
+// Package runner executes the external commands of the step through an interface,
+// so that the executed command plan can be recorded and asserted on.
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/kballard/go-shellquote"
+)
+
+// Command is an external command to be executed.
+type Command struct {
+	Name string
+	Args []string
+	// Dir is the working directory of the command, the step's working directory if empty.
+	Dir string
+	// Env holds additional environment variables in KEY=value form, on top of the step's environment.
+	Env []string
+	// UnsetEnv holds the names of the step's environment variables, which are not passed to the command.
+	// Variables set in Env are passed regardless.
+	UnsetEnv []string
+	// Stdout and Stderr receive the output of the command, os.Stdout and os.Stderr if nil.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Timeout is the time the command may run for, before its whole process group is killed. No limit if zero.
+	Timeout time.Duration
+}
+
+// New returns a Command.
+func New(name string, args ...string) Command {
+	return Command{Name: name, Args: args}
+}
+
+// String returns the printable form of the command.
+func (c Command) String() string {
+	return shellquote.Join(append([]string{c.Name}, c.Args...)...)
+}
+
+// TimeoutError is returned, if a command was killed as it did not finish within its Timeout.
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Error implements error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Command, e.Timeout)
+}
+
+// Runner executes commands.
+type Runner interface {
+	// Run executes the command, streaming its output.
+	Run(cmd Command) error
+	// Output executes the command and returns its trimmed combined output.
+	Output(cmd Command) (string, error)
+}
+
+// CommandRunner executes commands as subprocesses.
+type CommandRunner struct{}
+
+// NewCommandRunner returns a CommandRunner.
+func NewCommandRunner() CommandRunner {
+	return CommandRunner{}
+}
+
+// Run implements Runner.
+func (CommandRunner) Run(cmd Command) error {
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if cmd.Timeout > 0 {
+		return runWithTimeout(cmd, stdout, stderr)
+	}
+	return model(cmd).SetStdout(stdout).SetStderr(stderr).Run()
+}
+
+// Output implements Runner.
+func (CommandRunner) Output(cmd Command) (string, error) {
+	if cmd.Timeout > 0 {
+		var out bytes.Buffer
+		err := runWithTimeout(cmd, &out, &out)
+		return strings.TrimSpace(out.String()), err
+	}
+	return model(cmd).RunAndReturnTrimmedCombinedOutput()
+}
+
+// runWithTimeout executes the command in a new process group, which is killed as a whole when the timeout fires,
+// so that no subprocess (like a gem's native extension build) keeps running.
+func runWithTimeout(cmd Command, stdout, stderr io.Writer) error {
+	c := exec.Command(cmd.Name, cmd.Args...)
+	c.Dir = cmd.Dir
+	if len(cmd.Env) > 0 || len(cmd.UnsetEnv) > 0 {
+		c.Env = append(environ(cmd.UnsetEnv), cmd.Env...)
+	}
+	c.Stdout, c.Stderr = stdout, stderr
+	setProcessGroup(c)
+
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	timer := time.NewTimer(cmd.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		killProcessGroup(c)
+		<-done
+		return &TimeoutError{Command: cmd.String(), Timeout: cmd.Timeout}
+	}
+}
+
+func model(cmd Command) *command.Model {
+	m := command.New(cmd.Name, cmd.Args...)
+	if cmd.Dir != "" {
+		m.SetDir(cmd.Dir)
+	}
+	if len(cmd.Env) > 0 || len(cmd.UnsetEnv) > 0 {
+		m.SetEnvs(append(environ(cmd.UnsetEnv), cmd.Env...)...)
+	}
+	return m
+}
+
+// environ returns the step's environment without the given variables.
+func environ(unset []string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !contains(unset, strings.SplitN(kv, "=", 2)[0]) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubbingRunner removes the given variables from the environment of every command, before passing it to the
+// underlying Runner. Commands still receive the variables they explicitly set in Command.Env.
+type ScrubbingRunner struct {
+	runner Runner
+	keys   []string
+}
+
+// NewScrubbingRunner returns a ScrubbingRunner.
+func NewScrubbingRunner(r Runner, keys []string) ScrubbingRunner {
+	return ScrubbingRunner{runner: r, keys: keys}
+}
+
+// Run implements Runner.
+func (r ScrubbingRunner) Run(cmd Command) error {
+	return r.runner.Run(r.scrub(cmd))
+}
+
+// Output implements Runner.
+func (r ScrubbingRunner) Output(cmd Command) (string, error) {
+	return r.runner.Output(r.scrub(cmd))
+}
+
+func (r ScrubbingRunner) scrub(cmd Command) Command {
+	cmd.UnsetEnv = append(append([]string{}, cmd.UnsetEnv...), r.keys...)
+	return cmd
+}
+
+// EnvRunner adds the given variables to the environment of every command, before passing it to the
+// underlying Runner. The variables the command explicitly sets in Command.Env take precedence.
+type EnvRunner struct {
+	runner Runner
+	env    []string
+}
+
+// NewEnvRunner returns an EnvRunner.
+func NewEnvRunner(r Runner, env []string) EnvRunner {
+	return EnvRunner{runner: r, env: env}
+}
+
+// Run implements Runner.
+func (r EnvRunner) Run(cmd Command) error {
+	return r.runner.Run(r.withEnv(cmd))
+}
+
+// Output implements Runner.
+func (r EnvRunner) Output(cmd Command) (string, error) {
+	return r.runner.Output(r.withEnv(cmd))
+}
+
+func (r EnvRunner) withEnv(cmd Command) Command {
+	if len(r.env) > 0 {
+		cmd.Env = append(append([]string{}, r.env...), cmd.Env...)
+	}
+	return cmd
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRunner(t *testing.T) {
+	r := NewCommandRunner()
+
+	out, err := r.Output(Command{Name: "sh", Args: []string{"-c", "echo $RUNNER_TEST_ENV; pwd"}, Dir: "/", Env: []string{"RUNNER_TEST_ENV=value"}})
+	require.NoError(t, err)
+	require.Equal(t, "value\n/", out)
+
+	var stdout bytes.Buffer
+	require.NoError(t, r.Run(Command{Name: "echo", Args: []string{"hello"}, Stdout: &stdout}))
+	require.Equal(t, "hello\n", stdout.String())
+
+	require.Error(t, r.Run(Command{Name: "sh", Args: []string{"-c", "exit 3"}, Stdout: &stdout, Stderr: &stdout}))
+}
+
+func TestEnvRunner(t *testing.T) {
+	r := NewEnvRunner(NewCommandRunner(), []string{"RUNNER_TEST_PROXY=http://proxy:3128", "RUNNER_TEST_CA=/certs/ca.pem"})
+
+	out, err := r.Output(Command{Name: "sh", Args: []string{"-c", "echo $RUNNER_TEST_PROXY $RUNNER_TEST_CA"}, Env: []string{"RUNNER_TEST_CA=/explicit.pem"}})
+	require.NoError(t, err)
+	require.Equal(t, "http://proxy:3128 /explicit.pem", out)
+
+	fake := NewFakeRunner(nil)
+	require.NoError(t, NewEnvRunner(fake, nil).Run(New("true")))
+	require.Nil(t, fake.Commands[0].Env)
+}
+
+func TestFakeRunner(t *testing.T) {
+	r := NewFakeRunner(map[string]FakeResult{
+		"danger --version": {Output: "8.4.0\n"},
+		"bundle install":   {Output: "Could not find gem", Err: errors.New("exit status 1")},
+	})
+
+	version, err := r.Output(New("danger", "--version"))
+	require.NoError(t, err)
+	require.Equal(t, "8.4.0", version)
+
+	var stdout bytes.Buffer
+	cmd := New("bundle", "install")
+	cmd.Stdout = &stdout
+	require.EqualError(t, r.Run(cmd), "exit status 1")
+	require.Equal(t, "Could not find gem", stdout.String())
+
+	require.NoError(t, r.Run(New("bundle", "exec", "danger", "--dangerfile=/tmp/Dangerfile with space")))
+
+	require.Equal(t, []string{
+		"danger --version",
+		"bundle install",
+		`bundle exec danger '--dangerfile=/tmp/Dangerfile with space'`,
+	}, r.Plan())
+
+	_, err = r.Command("gem list")
+	require.EqualError(t, err, "command not executed: gem list")
+}
+
+func TestScrubbingRunner(t *testing.T) {
+	require.NoError(t, os.Setenv("RUNNER_TEST_SECRET", "secret"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("RUNNER_TEST_SECRET"))
+	}()
+
+	r := NewScrubbingRunner(NewCommandRunner(), []string{"RUNNER_TEST_SECRET"})
+
+	out, err := r.Output(New("sh", "-c", "echo secret=$RUNNER_TEST_SECRET"))
+	require.NoError(t, err)
+	require.Equal(t, "secret=", out)
+
+	out, err = r.Output(Command{Name: "sh", Args: []string{"-c", "echo secret=$RUNNER_TEST_SECRET"}, Env: []string{"RUNNER_TEST_SECRET=explicit"}})
+	require.NoError(t, err)
+	require.Equal(t, "secret=explicit", out)
+
+	fake := NewFakeRunner(nil)
+	require.NoError(t, NewScrubbingRunner(fake, []string{"A", "B"}).Run(Command{Name: "true", UnsetEnv: []string{"C"}}))
+	require.Equal(t, []string{"C", "A", "B"}, fake.Commands[0].UnsetEnv)
+}
@@ -0,0 +1,80 @@
+// Package runtime knows how to run Ruby Danger.
+package runtime
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/runner"
+)
+
+// DefaultDangerfile is the Dangerfile Danger evaluates, if not specified otherwise.
+const DefaultDangerfile = "Dangerfile"
+
+// ExtractDangerfileOption removes the --dangerfile option from the additional options
+// and returns the absolute path of the Dangerfile to be evaluated, relative paths resolved against workDir.
+func ExtractDangerfileOption(options []string, workDir string) (string, []string) {
+	dangerfile := DefaultDangerfile
+	var remaining []string
+	for i := 0; i < len(options); i++ {
+		option := options[i]
+		switch {
+		case strings.HasPrefix(option, "--dangerfile="):
+			dangerfile = strings.TrimPrefix(option, "--dangerfile=")
+		case option == "--dangerfile" && i+1 < len(options):
+			dangerfile = options[i+1]
+			i++
+		default:
+			remaining = append(remaining, option)
+		}
+	}
+
+	if !filepath.IsAbs(dangerfile) {
+		dangerfile = filepath.Join(workDir, dangerfile)
+	}
+	return dangerfile, remaining
+}
+
+// DangerCommand returns the command running Danger with the given Dangerfile and additional options.
+func DangerCommand(dangerfile string, options []string) runner.Command {
+	return runner.New("bundle", append([]string{"exec", "danger", "--dangerfile=" + dangerfile}, options...)...)
+}
+
+// TrimScheme trims the URL if danger version is <8.0.5
+func TrimScheme(r runner.Runner, url string) string {
+	cmd := runner.New("danger", "--version")
+	log.Printf("$ %s", cmd)
+
+	dangerVersion, err := r.Output(cmd)
+	if err != nil {
+		log.Errorf("Could not determine danger vesion: %s", err)
+		return url
+	}
+
+	log.Printf("Found danger version: %s", dangerVersion)
+
+	if ShouldTrimScheme(dangerVersion) {
+		return strings.TrimLeft(url, "https://")
+	}
+
+	return url
+}
+
+// ShouldTrimScheme returns whether the given danger version requires the repository URL without scheme.
+func ShouldTrimScheme(rawDangerVersion string) bool {
+	dangerVersion, err := semver.NewVersion(rawDangerVersion)
+	if err != nil {
+		log.Errorf("Could not parse danger vesion: %s", err)
+		return false
+	}
+
+	versionConstraint, err := semver.NewConstraint("<8.0.5")
+	if err != nil {
+		log.Errorf("Could not parse version constraint: %s", err)
+		return false
+	}
+
+	return versionConstraint.Check(dangerVersion)
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ShouldTrimScheme(t *testing.T) {
+	scenarios := []struct {
+		input    string
+		expected bool
+	}{
+		{"8", true},
+		{"8.0", true},
+		{"8.0.0", true},
+		{"8.0.4", true},
+		{"8.0.5", false},
+		{"8.0.6", false},
+		{"8.1.0", false},
+		{"9", false},
+	}
+
+	for _, scenario := range scenarios {
+		acutalResult := ShouldTrimScheme(scenario.input)
+		require.Equal(t, scenario.expected, acutalResult)
+	}
+}
+
+func TestTrimScheme(t *testing.T) {
+	url := "https://github.com/bitrise-io/sample-apps-ios-simple-objc.git"
+
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"danger --version": {Output: "8.0.4\n"}})
+	require.Equal(t, "github.com/bitrise-io/sample-apps-ios-simple-objc.git", TrimScheme(r, url))
+	require.Equal(t, []string{"danger --version"}, r.Plan())
+
+	r = runner.NewFakeRunner(map[string]runner.FakeResult{"danger --version": {Output: "8.4.0\n"}})
+	require.Equal(t, url, TrimScheme(r, url))
+
+	r = runner.NewFakeRunner(map[string]runner.FakeResult{"danger --version": {Err: errors.New("executable file not found in $PATH")}})
+	require.Equal(t, url, TrimScheme(r, url))
+}
+
+func TestExtractDangerfileOption(t *testing.T) {
+	scenarios := []struct {
+		input              []string
+		expectedDangerfile string
+		expectedOptions    []string
+	}{
+		{nil, "/project/Dangerfile", nil},
+		{[]string{"--fail-on-errors=true"}, "/project/Dangerfile", []string{"--fail-on-errors=true"}},
+		{[]string{"--dangerfile=ci/Dangerfile", "--verbose"}, "/project/ci/Dangerfile", []string{"--verbose"}},
+		{[]string{"--verbose", "--dangerfile", "/abs/Dangerfile"}, "/abs/Dangerfile", []string{"--verbose"}},
+	}
+
+	for _, scenario := range scenarios {
+		dangerfile, options := ExtractDangerfileOption(scenario.input, "/project")
+		require.Equal(t, scenario.expectedDangerfile, dangerfile)
+		require.Equal(t, scenario.expectedOptions, options)
+	}
+}
+
+func TestDangerCommand(t *testing.T) {
+	cmd := DangerCommand("/tmp/danger/Dangerfile", []string{"--fail-on-errors=true"})
+	require.Equal(t, "bundle exec danger --dangerfile=/tmp/danger/Dangerfile --fail-on-errors=true", cmd.String())
+}
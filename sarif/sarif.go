@@ -0,0 +1,151 @@
+// Package sarif converts the inline Danger violations to SARIF 2.1.0.
+package sarif
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+)
+
+// Version is the SARIF version written by this package.
+const Version = "2.1.0"
+
+// Schema is the JSON schema of the SARIF version written by this package.
+const Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Rule IDs of the Danger violation kinds.
+const (
+	RuleError   = "danger-error"
+	RuleWarning = "danger-warning"
+	RuleMessage = "danger-message"
+)
+
+// Log is the root object of a SARIF file.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the result of a single Dangerfile evaluation.
+type Run struct {
+	Tool       Tool              `json:"tool"`
+	Results    []Result          `json:"results"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// Tool describes the analysis tool.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver describes the analysis tool's primary component.
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes a kind of result reported by the tool.
+type Rule struct {
+	ID               string  `json:"id"`
+	ShortDescription Message `json:"shortDescription"`
+}
+
+// Result is a single violation.
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+}
+
+// Message is a SARIF message object.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is the location of a result.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a location within a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           Region           `json:"region"`
+}
+
+// ArtifactLocation identifies a file.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a region within a file.
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+var rules = []Rule{
+	{ID: RuleError, ShortDescription: Message{Text: "Danger error"}},
+	{ID: RuleWarning, ShortDescription: Message{Text: "Danger warning"}},
+	{ID: RuleMessage, ShortDescription: Message{Text: "Danger message"}},
+}
+
+// Convert converts the results of a Danger run to a SARIF log, each Dangerfile becoming a run.
+// Only the inline violations (the ones having both file and line) are converted.
+func Convert(dangerResults results.Results) Log {
+	log := Log{Schema: Schema, Version: Version, Runs: []Run{}}
+
+	for _, dangerfile := range dangerResults.Dangerfiles {
+		run := Run{
+			Tool: Tool{Driver: Driver{
+				Name:           "Danger",
+				InformationURI: "https://danger.systems",
+				Rules:          rules,
+			}},
+			Results:    []Result{},
+			Properties: map[string]string{"dangerfile": dangerfile.Path},
+		}
+
+		for _, group := range []struct {
+			ruleID     string
+			level      string
+			violations []results.Violation
+		}{
+			{RuleError, "error", dangerfile.Errors},
+			{RuleWarning, "warning", dangerfile.Warnings},
+			{RuleMessage, "note", dangerfile.Messages},
+		} {
+			for _, violation := range group.violations {
+				if violation.File == "" || violation.Line < 1 {
+					continue
+				}
+
+				run.Results = append(run.Results, Result{
+					RuleID:  group.ruleID,
+					Level:   group.level,
+					Message: Message{Text: violation.Message},
+					Locations: []Location{{PhysicalLocation: PhysicalLocation{
+						ArtifactLocation: ArtifactLocation{URI: violation.File},
+						Region:           Region{StartLine: violation.Line},
+					}}},
+				})
+			}
+		}
+
+		log.Runs = append(log.Runs, run)
+	}
+
+	return log
+}
+
+// WriteFile writes the JSON representation of the SARIF log to pth.
+func (l Log) WriteFile(pth string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pth, data, 0644)
+}
@@ -0,0 +1,66 @@
+package sarif
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	dangerResults := results.Results{Dangerfiles: []results.Dangerfile{
+		{
+			Path:      "/project/Dangerfile",
+			Errors:    []results.Violation{{Message: "Big PR"}, {Message: "Debug code", File: "main.go", Line: 3}},
+			Warnings:  []results.Violation{{Message: "No line", File: "main.go"}},
+			Messages:  []results.Violation{{Message: "Nice", File: "README.md", Line: 1}},
+			Markdowns: []results.Violation{{Message: "## Summary", File: "main.go", Line: 1}},
+		},
+		{
+			Path: "/tmp/rules/Dangerfile",
+		},
+	}}
+
+	log := Convert(dangerResults)
+
+	require.Equal(t, Version, log.Version)
+	require.Equal(t, Schema, log.Schema)
+	require.Len(t, log.Runs, 2)
+
+	require.Equal(t, map[string]string{"dangerfile": "/project/Dangerfile"}, log.Runs[0].Properties)
+	require.Equal(t, "Danger", log.Runs[0].Tool.Driver.Name)
+	require.Equal(t, []Result{
+		{
+			RuleID:  RuleError,
+			Level:   "error",
+			Message: Message{Text: "Debug code"},
+			Locations: []Location{{PhysicalLocation: PhysicalLocation{
+				ArtifactLocation: ArtifactLocation{URI: "main.go"},
+				Region:           Region{StartLine: 3},
+			}}},
+		},
+		{
+			RuleID:  RuleMessage,
+			Level:   "note",
+			Message: Message{Text: "Nice"},
+			Locations: []Location{{PhysicalLocation: PhysicalLocation{
+				ArtifactLocation: ArtifactLocation{URI: "README.md"},
+				Region:           Region{StartLine: 1},
+			}}},
+		},
+	}, log.Runs[0].Results)
+
+	require.Equal(t, map[string]string{"dangerfile": "/tmp/rules/Dangerfile"}, log.Runs[1].Properties)
+	require.Equal(t, []Result{}, log.Runs[1].Results)
+}
+
+func TestLog_WriteFile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "danger.sarif")
+	require.NoError(t, Convert(results.Results{}).WriteFile(pth))
+
+	data, err := ioutil.ReadFile(pth)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"$schema": "https://json.schemastore.org/sarif-2.1.0.json", "version": "2.1.0", "runs": []}`, string(data))
+}
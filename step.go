@@ -0,0 +1,431 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/config"
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/githubapp"
+	"github.com/bitrise-steplib/steps-danger/installer"
+	"github.com/bitrise-steplib/steps-danger/network"
+	"github.com/bitrise-steplib/steps-danger/preflight"
+	"github.com/bitrise-steplib/steps-danger/provider"
+	"github.com/bitrise-steplib/steps-danger/pullrequest"
+	"github.com/bitrise-steplib/steps-danger/redact"
+	"github.com/bitrise-steplib/steps-danger/results"
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/bitrise-steplib/steps-danger/runtime"
+	"github.com/kballard/go-shellquote"
+)
+
+// outputExporter exports the step outputs.
+type outputExporter interface {
+	ExportOutput(key, value string) error
+}
+
+// dangerStep installs the dependencies of the project and runs Danger.
+type dangerStep struct {
+	cfg      config.Config
+	runner   runner.Runner
+	redactor *redact.Redactor
+	exporter outputExporter
+	workDir  string
+	tmpDir   string
+
+	// rawRunner reads the contents of files, its output is neither redacted, nor retried into the same writer.
+	rawRunner runner.Runner
+
+	// cacheDir is where the gems are installed, if cache_dependencies is set.
+	cacheDir    string
+	cacheConfig cache.Config
+
+	// sleep waits for the GitHub API rate limit to reset.
+	sleep func(time.Duration)
+	// state is shared by the copies of the step, so that the diagnostics see what Run resolved.
+	state *runState
+	// dryRun records the commands, which are not executed in dry run mode. Nil if dry_run is not set.
+	dryRun *runner.DryRunner
+}
+
+// newDangerStep returns a dangerStep. The API tokens are removed from the environment of every command,
+// only the Danger run receives them explicitly, and they are masked in the output of every command,
+// except the contents of the files read from the target branch, which are written as is.
+// Every command connects through the configured proxy and trusts the configured CA bundle.
+// The commands failing with a transient error are retried, as configured.
+func newDangerStep(cfg config.Config, r runner.Runner, exporter outputExporter, workDir, tmpDir string) dangerStep {
+	var dryRun *runner.DryRunner
+	if cfg.DryRun {
+		dryRun = runner.NewDryRunner(r, "danger --version", "gem list")
+		r = dryRun
+	}
+
+	secretKeys, networkEnv := cfg.SecretEnvKeysIn(os.Environ()), stepNetwork(cfg, tmpDir).Env()
+	rawRunner := runner.NewEnvRunner(runner.NewScrubbingRunner(r, secretKeys), networkEnv)
+
+	redactor := redact.New(cfg.Secrets()...)
+	r = runner.NewRedactingRunner(r, redactor)
+	r = runner.NewScrubbingRunner(r, secretKeys)
+	r = runner.NewEnvRunner(r, networkEnv)
+	r = runner.NewRetryingRunner(r, runner.RetryPolicy{
+		Attempts:    cfg.RetryAttempts,
+		Backoff:     time.Duration(cfg.RetryBackoff) * time.Second,
+		IsTransient: failure.IsTransient,
+	})
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = tmpDir
+	}
+
+	return dangerStep{
+		cfg:      cfg,
+		runner:   r,
+		redactor: redactor,
+		exporter: exporter,
+		workDir:  workDir,
+		tmpDir:   tmpDir,
+
+		rawRunner: rawRunner,
+
+		cacheDir: filepath.Join(cacheDir, "bitrise-step-danger"),
+		cacheConfig: cache.Config{
+			VariableGetter:  cache.NewOSVariableGetter(),
+			VariableSetters: []cache.VariableSetter{cache.NewOSVariableSetter(), cache.NewEnvmanVariableSetter()},
+		},
+		sleep:  time.Sleep,
+		state:  &runState{},
+		dryRun: dryRun,
+	}
+}
+
+// stepNetwork returns the network configuration of the commands, exporting the CA bundle
+// combined with the system roots from tmpDir.
+func stepNetwork(cfg config.Config, tmpDir string) network.Config {
+	n := cfg.Network()
+	n.TrustStorePath = filepath.Join(tmpDir, "ca-bundle.pem")
+	return n
+}
+
+// Run runs the step. Failures of Danger or its dependencies are returned as *failure.Error.
+func (s dangerStep) Run() error {
+	cfg := s.cfg
+	cfg.RepositoryURL = runtime.TrimScheme(s.runner, cfg.RepositoryURL)
+
+	stepconf.Print(cfg)
+	fmt.Println()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("issue with input: %s", err)
+	}
+
+	httpClient, err := cfg.Network().HTTPClient(30 * time.Second)
+	if err != nil {
+		return fmt.Errorf("issue with input: %s", err)
+	}
+	if err := stepNetwork(cfg, s.tmpDir).WriteTrustStore(); err != nil {
+		return err
+	}
+
+	if s.dryRun != nil {
+		log.Warnf("Dry run: nothing is installed and Danger does not run, the commands are only printed")
+		fmt.Println()
+	}
+
+	trusted := cfg.TrustedDangerfile || cfg.TrustedGemfile
+	restricted := false
+	if pullrequest.IsFork(s.cfg.RepositoryURL, cfg.PullRequestRepositoryURL) {
+		log.Warnf("The pull request was opened from a fork: %s", cfg.PullRequestRepositoryURL)
+		switch cfg.ForkPRPolicy {
+		case pullrequest.ForkPolicySkip:
+			log.Warnf("Skipping Danger, as fork_pr_policy is set to %s", cfg.ForkPRPolicy)
+			return nil
+		case pullrequest.ForkPolicyRestrictedToken:
+			log.Warnf("Running Danger with the restricted fork_pr_api_token")
+			cfg = cfg.WithRestrictedToken()
+			restricted = true
+		case pullrequest.ForkPolicyBaseDangerfile:
+			// Bundler evaluates the Gemfile as Ruby with the token in reach, the fork's one can not be trusted either.
+			log.Warnf("Running the Dangerfile and the Gemfile of the %s branch", cfg.PullRequestDestBranch)
+			cfg.TrustedDangerfile = true
+			cfg.TrustedGemfile = true
+			trusted = true
+		}
+		fmt.Println()
+	}
+
+	if cfg.UsesGitHubApp() {
+		if s.dryRun != nil {
+			log.Printf("Dry run: skipping the GitHub App token exchange")
+			cfg.GithubAPIToken = "<GitHub App installation token>"
+		} else {
+			token, err := s.githubAppToken(cfg, httpClient)
+			if err != nil {
+				return err
+			}
+			cfg.GithubAPIToken = token
+		}
+	}
+
+	if cfg.ValidateTokens {
+		if s.dryRun != nil {
+			log.Printf("Dry run: skipping the API token check")
+		} else if err := s.checkTokens(cfg, httpClient, restricted); err != nil {
+			return err
+		}
+	}
+
+	additionalOptions, err := shellquote.Split(cfg.AdditionalOptions)
+	if err != nil {
+		return fmt.Errorf("failed to shell-quote additional options (%s): %s", cfg.AdditionalOptions, err)
+	}
+
+	dangerfile, additionalOptions := runtime.ExtractDangerfileOption(additionalOptions, s.workDir)
+
+	gemfile := ""
+	if trusted {
+		if cfg.PullRequestDestBranch == "" {
+			log.Warnf("Not a pull request build, using the checked out Dangerfile and Gemfile")
+		} else if s.dryRun != nil {
+			log.Printf("Dry run: skipping reading the trusted files from the %s branch", cfg.PullRequestDestBranch)
+		} else {
+			files, err := s.fetchTrustedFiles(cfg, dangerfile)
+			if err != nil {
+				return err
+			}
+			if files.dangerfile != "" {
+				dangerfile = files.dangerfile
+			}
+			gemfile = files.gemfile
+		}
+		fmt.Println()
+	}
+
+	dangerfiles, err := s.rulesDangerfiles(cfg.Rules, dangerfile)
+	if err != nil {
+		return err
+	}
+	s.state.dangerfiles = dangerfiles
+
+	dependencies := installer.New(s.runner, s.workDir)
+	if gemfile != "" {
+		dependencies = dependencies.WithGemfile(gemfile)
+	}
+	if err := s.checkProjectFiles(dangerfiles, dependencies.Gemfile()); err != nil {
+		return err
+	}
+
+	//
+	// Check dependencies
+	log.Infof("Checking dependencies")
+	log.Printf("Bundler...")
+
+	installOutput := failure.NewOutputTail(64 * 1024)
+	dependencies = dependencies.WithOutput(installOutput)
+	installTimeout := time.Duration(cfg.InstallTimeout) * time.Second
+	if installTimeout > 0 {
+		dependencies = dependencies.WithDeadline(time.Now().Add(installTimeout))
+	}
+	var gems gemCache
+	if cfg.CacheDependencies {
+		gems = s.openGemCache(dependencies.Gemfile() + ".lock")
+		dependencies = dependencies.WithBundlePath(gems.dir.BundlePath())
+	}
+	s.state.bundleEnv = dependencies.Env()
+	if err := dependencies.EnsureBundler(); err != nil {
+		if timeoutErr := phaseTimeout("install", installTimeout, err, installOutput); timeoutErr != nil {
+			return timeoutErr
+		}
+		return &failure.Error{Kind: failure.KindDependencyInstall, Err: err}
+	}
+
+	//
+	// Danger
+	fmt.Println()
+	log.Infof("Installing dependencies from your gem file")
+
+	if err := dependencies.EnsureGems(); err != nil {
+		if timeoutErr := phaseTimeout("install", installTimeout, err, installOutput); timeoutErr != nil {
+			return timeoutErr
+		}
+		return failure.Errorf(failure.KindDependencyInstall, "failed to run bundle install, error: %s", err)
+	}
+	if cfg.CacheDependencies && s.dryRun == nil {
+		s.commitGemCache(gems)
+	}
+
+	fmt.Println()
+	if cfg.RateLimitThreshold > 0 && s.dryRun == nil && !s.checkRateLimit(cfg, httpClient) {
+		return nil
+	}
+
+	log.Infof("Running danger")
+
+	resultsPth := filepath.Join(s.tmpDir, "results.json")
+	wrapperPth := filepath.Join(s.tmpDir, "Dangerfile")
+	if err := fileutil.WriteStringToFile(wrapperPth, results.WrapperDangerfile(dangerfiles, resultsPth)); err != nil {
+		return fmt.Errorf("failed to write wrapper Dangerfile: %s", err)
+	}
+
+	dangerOutput := failure.NewOutputTail(64 * 1024)
+	cmd := runtime.DangerCommand(wrapperPth, additionalOptions)
+	cmd.Dir = s.workDir
+	cmd.Env = dangerEnv(cfg, dependencies.Env())
+	cmd.Stdout = io.MultiWriter(os.Stdout, dangerOutput)
+	cmd.Stderr = io.MultiWriter(os.Stderr, dangerOutput)
+	cmd.Timeout = time.Duration(cfg.DangerTimeout) * time.Second
+	log.Printf("$ %s", cmd)
+
+	dangerErr := s.runner.Run(cmd)
+	if s.dryRun != nil {
+		s.printPlan(cfg, dangerfiles)
+		return nil
+	}
+
+	fmt.Println()
+	var dangerResults *results.Results
+	if r, err := results.ReadFile(resultsPth); err != nil {
+		log.Warnf("Could not read danger results, outputs are not exported: %s", err)
+	} else {
+		dangerResults = &r
+		s.exportResults(r)
+		s.exportReport(r)
+		s.exportJUnit(r)
+		s.exportSARIF(r)
+	}
+
+	if dangerErr != nil {
+		fmt.Println()
+		if timeoutErr := phaseTimeout("danger", cmd.Timeout, dangerErr, dangerOutput); timeoutErr != nil {
+			return timeoutErr
+		}
+		kind := failure.ClassifyDangerRun(dangerOutput.String(), dangerResults)
+		if kind == failure.KindRuleViolation && dangerResults != nil {
+			return failure.Errorf(kind, "Danger reported %d error(s)", dangerResults.ErrorsCount())
+		}
+		return failure.Errorf(kind, "failed to run bundle exec danger, error: %s", dangerErr)
+	}
+
+	// Danger only fails on errors with --fail-on-errors=true, the failure policy applies regardless of it.
+	if dangerResults != nil && dangerResults.ErrorsCount() > 0 {
+		fmt.Println()
+		return failure.Errorf(failure.KindRuleViolation, "Danger reported %d error(s)", dangerResults.ErrorsCount())
+	}
+
+	return nil
+}
+
+// checkProjectFiles verifies the Dangerfiles and the Gemfile.lock, before spending time on installing the dependencies.
+func (s dangerStep) checkProjectFiles(dangerfiles []string, gemfile string) error {
+	log.Infof("Checking the Dangerfile and the Gemfile.lock")
+
+	for _, dangerfile := range dangerfiles {
+		if err := preflight.CheckDangerfile(s.runner, s.workDir, dangerfile); err != nil {
+			return err
+		}
+	}
+	version, err := preflight.CheckLockfile(gemfile)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		log.Printf("Gemfile.lock bundles danger %s", version)
+	}
+	fmt.Println()
+	return nil
+}
+
+// checkTokens verifies the API token of every configured provider against the provider's API.
+// The restricted token of fork pull requests is only required to be accepted, its missing scopes are logged.
+func (s dangerStep) checkTokens(cfg config.Config, client *http.Client, restricted bool) error {
+	log.Infof("Checking API tokens")
+
+	checker := preflight.New(client)
+	if restricted {
+		checker = checker.WithAdvisedScopes()
+	}
+	for _, p := range cfg.Providers() {
+		if !p.IsConfigured() {
+			continue
+		}
+		// Installation tokens can not access /user, the token exchange already checked the App's permissions.
+		if p.Key == "github" && cfg.UsesGitHubApp() {
+			log.Printf("Using a GitHub App installation token, skipping the GitHub token check")
+			continue
+		}
+		if err := checker.Check(p); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
+
+// githubAppToken exchanges the GitHub App's credentials for an installation token, which is only passed to Danger.
+func (s dangerStep) githubAppToken(cfg config.Config, client *http.Client) (stepconf.Secret, error) {
+	log.Infof("Authenticating as GitHub App %s", cfg.GithubAppID)
+
+	key, err := githubapp.ParsePrivateKey(string(cfg.GithubAppPrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("issue with input: github_app_private_key: %s", err)
+	}
+
+	app := githubapp.App{ID: cfg.GithubAppID, InstallationID: cfg.GithubAppInstallationID, PrivateKey: key}
+	apiURL := provider.NewGitHub("", cfg.GithubHost, cfg.GithubAPIBaseURL).APIURL()
+	token, err := githubapp.NewClient(client, apiURL).InstallationToken(app)
+	if err != nil {
+		return "", err
+	}
+	s.redactor.Add(token)
+
+	fmt.Println()
+	return stepconf.Secret(token), nil
+}
+
+// phaseTimeout returns a timeout failure naming the phase and its last output lines, if err is a *runner.TimeoutError.
+func phaseTimeout(phase string, timeout time.Duration, err error, output *failure.OutputTail) error {
+	var timeoutErr *runner.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("the %s phase timed out after %s, killed: %s", phase, timeout, timeoutErr.Command)
+	if lines := output.LastLines(20); len(lines) > 0 {
+		msg += "\nLast output lines:\n" + strings.Join(lines, "\n")
+	}
+	return &failure.Error{Kind: failure.KindTimeout, Err: errors.New(msg)}
+}
+
+// dangerEnv returns the environment variables Danger reads its configuration from, in KEY=value form,
+// along with the Bundler configuration the gems were installed with.
+func dangerEnv(cfg config.Config, bundleEnv []string) []string {
+	envs := map[string]string{"GIT_REPOSITORY_URL": cfg.RepositoryURL}
+	for _, env := range bundleEnv {
+		kv := strings.SplitN(env, "=", 2)
+		envs[kv[0]] = kv[1]
+	}
+	for _, p := range cfg.Providers() {
+		for key, value := range p.Envs() {
+			envs[key] = value
+		}
+	}
+
+	var env []string
+	for key, value := range envs {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
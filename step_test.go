@@ -0,0 +1,1007 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/bitrise-steplib/steps-danger/config"
+	"github.com/bitrise-steplib/steps-danger/failure"
+	"github.com/bitrise-steplib/steps-danger/pullrequest"
+	"github.com/bitrise-steplib/steps-danger/runner"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	repositoryURL     = "https://github.com/bitrise-io/sample-apps-ios-simple-objc.git"
+	forkRepositoryURL = "https://github.com/contributor/sample-apps-ios-simple-objc.git"
+)
+
+const gemfileLock = `GEM
+  remote: https://rubygems.org/
+  specs:
+    danger (8.4.0)
+
+DEPENDENCIES
+  danger
+
+BUNDLED WITH
+   2.2.24
+`
+
+type fakeExporter map[string]string
+
+func (e fakeExporter) ExportOutput(key, value string) error {
+	e[key] = value
+	return nil
+}
+
+func TestDangerStep_Run(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		cfg          config.Config
+		dangerfile   string
+		gemfileLock  string
+		results      map[string]runner.FakeResult
+		expectedPlan []string
+		expectedEnv  []string
+		expectedKind failure.Kind
+	}{
+		{
+			name:    "GitHub, bundler installed",
+			cfg:     config.Config{RepositoryURL: repositoryURL, AdditionalOptions: "--fail-on-errors=true", GithubAPIToken: "github-token"},
+			results: map[string]runner.FakeResult{"danger --version": {Output: "8.4.0"}, "gem list": {Output: "bundler (2.2.24)"}},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile --fail-on-errors=true",
+			},
+			expectedEnv: []string{
+				"DANGER_GITHUB_API_TOKEN=github-token",
+				"GIT_REPOSITORY_URL=" + repositoryURL,
+			},
+		},
+		{
+			name: "GitLab Enterprise, danger <8.0.5, locked bundler not installed",
+			cfg: config.Config{
+				RepositoryURL:    repositoryURL,
+				GitlabAPIToken:   "gitlab-token",
+				GitlabHost:       "git.corp.evilcorp.com",
+				GitlabAPIBaseURL: "https://git.corp.evilcorp.com/api/v4",
+			},
+			gemfileLock: gemfileLock,
+			results: map[string]runner.FakeResult{
+				"danger --version":       {Output: "8.0.4"},
+				"gem list":               {Output: "bundler (1.17.3)"},
+				"bundle check --dry-run": {Err: errors.New("exit status 1")},
+			},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"gem install bundler --force --no-document --version 2.2.24",
+				"bundle check --dry-run",
+				"bundle install",
+				"bundle exec danger --dangerfile=TMP/Dangerfile",
+			},
+			expectedEnv: []string{
+				"DANGER_GITLAB_API_BASE_URL=https://git.corp.evilcorp.com/api/v4",
+				"DANGER_GITLAB_API_TOKEN=gitlab-token",
+				"DANGER_GITLAB_HOST=git.corp.evilcorp.com",
+				"GIT_REPOSITORY_URL=github.com/bitrise-io/sample-apps-ios-simple-objc.git",
+			},
+		},
+		{
+			name:       "danger not installed globally, custom Dangerfile",
+			cfg:        config.Config{RepositoryURL: repositoryURL, AdditionalOptions: "--dangerfile=ci/Dangerfile --verbose", GithubAPIToken: "github-token"},
+			dangerfile: "ci/Dangerfile",
+			results:    map[string]runner.FakeResult{"danger --version": {Err: errors.New("executable file not found in $PATH")}, "gem list": {Output: "bundler (2.2.24)"}},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/ci/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile --verbose",
+			},
+			expectedEnv: []string{
+				"DANGER_GITHUB_API_TOKEN=github-token",
+				"GIT_REPOSITORY_URL=" + repositoryURL,
+			},
+		},
+		{
+			name: "Dangerfile syntax error",
+			cfg:  config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token"},
+			results: map[string]runner.FakeResult{
+				"danger --version": {Output: "8.4.0"},
+				"ruby -c WORK/Dangerfile": {
+					Output: "WORK/Dangerfile:3: syntax error, unexpected end-of-input, expecting `end'",
+					Err:    errors.New("exit status 1"),
+				},
+			},
+			expectedPlan: []string{"danger --version", "ruby -c WORK/Dangerfile"},
+			expectedKind: failure.KindDangerfileException,
+		},
+		{
+			name:         "danger missing from Gemfile.lock",
+			cfg:          config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token"},
+			gemfileLock:  strings.Replace(gemfileLock, "danger", "fastlane", -1),
+			results:      map[string]runner.FakeResult{"danger --version": {Output: "8.4.0"}},
+			expectedPlan: []string{"danger --version", "ruby -c WORK/Dangerfile"},
+			expectedKind: failure.KindDependencyInstall,
+		},
+		{
+			name: "bundle install fails",
+			cfg:  config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token"},
+			results: map[string]runner.FakeResult{
+				"danger --version":       {Output: "8.4.0"},
+				"gem list":               {Output: "bundler (2.2.24)"},
+				"bundle check --dry-run": {Err: errors.New("exit status 1")},
+				"bundle install":         {Err: errors.New("exit status 5")},
+			},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle install",
+			},
+			expectedEnv: []string{
+				"DANGER_GITHUB_API_TOKEN=github-token",
+				"GIT_REPOSITORY_URL=" + repositoryURL,
+			},
+			expectedKind: failure.KindDependencyInstall,
+		},
+		{
+			name: "danger fails with bad credentials",
+			cfg:  config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token"},
+			results: map[string]runner.FakeResult{
+				"danger --version": {Output: "8.4.0"},
+				"gem list":         {Output: "bundler (2.2.24)"},
+				"bundle exec danger --dangerfile=TMP/Dangerfile": {
+					Output: "Octokit::Unauthorized: GET https://api.github.com/user: 401 - Bad credentials",
+					Err:    errors.New("exit status 1"),
+				},
+			},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile",
+			},
+			expectedEnv: []string{
+				"DANGER_GITHUB_API_TOKEN=github-token",
+				"GIT_REPOSITORY_URL=" + repositoryURL,
+			},
+			expectedKind: failure.KindAuthentication,
+		},
+		{
+			name:         "fork PR, skip",
+			cfg:          config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", PullRequestRepositoryURL: forkRepositoryURL, ForkPRPolicy: pullrequest.ForkPolicySkip},
+			results:      map[string]runner.FakeResult{"danger --version": {Output: "8.4.0"}},
+			expectedPlan: []string{"danger --version"},
+		},
+		{
+			name: "fork PR, restricted token",
+			cfg: config.Config{
+				RepositoryURL:            repositoryURL,
+				GithubAPIToken:           "github-token",
+				PullRequestRepositoryURL: forkRepositoryURL,
+				ForkPRPolicy:             pullrequest.ForkPolicyRestrictedToken,
+				ForkPRAPIToken:           "restricted-token",
+			},
+			results: map[string]runner.FakeResult{"danger --version": {Output: "8.4.0"}, "gem list": {Output: "bundler (2.2.24)"}},
+			expectedPlan: []string{
+				"danger --version",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile",
+			},
+			expectedEnv: []string{
+				"DANGER_GITHUB_API_TOKEN=restricted-token",
+				"GIT_REPOSITORY_URL=" + repositoryURL,
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			workDir, tmpDir := newWorkDir(t), t.TempDir()
+			if scenario.dangerfile != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(workDir, scenario.dangerfile)), 0700))
+				require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, scenario.dangerfile), []byte(`message("Hi")`), 0600))
+			}
+			if scenario.gemfileLock != "" {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "Gemfile.lock"), []byte(scenario.gemfileLock), 0600))
+			}
+
+			scriptedResults := map[string]runner.FakeResult{}
+			for cmd, result := range scenario.results {
+				result.Output = replaceDirs(result.Output, workDir, tmpDir)
+				scriptedResults[replaceDirs(cmd, workDir, tmpDir)] = result
+			}
+			r := runner.NewFakeRunner(scriptedResults)
+
+			err := newDangerStep(scenario.cfg, r, fakeExporter{}, workDir, tmpDir).Run()
+
+			if scenario.expectedKind == "" {
+				require.NoError(t, err)
+			} else {
+				var failureErr *failure.Error
+				require.True(t, errors.As(err, &failureErr), "%v", err)
+				require.Equal(t, scenario.expectedKind, failureErr.Kind)
+			}
+
+			var expectedPlan []string
+			for _, cmd := range scenario.expectedPlan {
+				expectedPlan = append(expectedPlan, replaceDirs(cmd, workDir, tmpDir))
+			}
+			require.Equal(t, expectedPlan, r.Plan())
+
+			secretKeys := scenario.cfg.SecretEnvKeys()
+			for _, cmd := range r.Commands {
+				require.Equal(t, secretKeys, cmd.UnsetEnv, cmd.String())
+			}
+			for _, cmd := range r.Commands[1:] {
+				require.Equal(t, workDir, cmd.Dir, cmd.String())
+				if strings.HasPrefix(cmd.String(), "bundle exec danger") {
+					require.Equal(t, scenario.expectedEnv, cmd.Env, cmd.String())
+				} else {
+					require.Empty(t, cmd.Env, cmd.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDangerStep_Run_InheritedSecrets(t *testing.T) {
+	// The github_api_token input is usually filled from a Bitrise secret, which keeps its own name.
+	require.NoError(t, os.Setenv("BOT_GITHUB_TOKEN", "github-token"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("BOT_GITHUB_TOKEN"))
+	}()
+
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token"}
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"bundle check --dry-run": {Err: errors.New("exit status 1")}})
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, newWorkDir(t), t.TempDir()).Run())
+
+	require.Contains(t, r.Plan(), "bundle install")
+	for _, cmd := range r.Commands {
+		require.Contains(t, cmd.UnsetEnv, "BOT_GITHUB_TOKEN", cmd.String())
+	}
+}
+
+func TestDangerStep_Run_Results(t *testing.T) {
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", DeployDir: t.TempDir()}
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+
+	// The wrapper Dangerfile dumps the results, even if Danger does not fail on errors.
+	content := `{"dangerfiles":[{"dangerfile":"` + filepath.Join(workDir, "Dangerfile") + `","errors":[{"message":"Big PR"}],"warnings":[],"messages":[{"message":"Hi"}],"markdowns":[]}]}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "results.json"), []byte(content), 0600))
+
+	exporter := fakeExporter{}
+	err := newDangerStep(cfg, runner.NewFakeRunner(nil), exporter, workDir, tmpDir).Run()
+
+	var failureErr *failure.Error
+	require.True(t, errors.As(err, &failureErr), "%v", err)
+	require.Equal(t, failure.KindRuleViolation, failureErr.Kind)
+	require.Equal(t, fakeExporter{
+		"DANGER_ERRORS_COUNT":      "1",
+		"DANGER_WARNINGS_COUNT":    "0",
+		"DANGER_MESSAGES_COUNT":    "1",
+		"DANGER_RESULT":            "fail",
+		"DANGER_RESULTS_JSON_PATH": filepath.Join(cfg.DeployDir, "danger-results.json"),
+	}, exporter)
+
+	wrapper, err := ioutil.ReadFile(filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Contains(t, string(wrapper), `bitrise_dangerfiles = ["`+filepath.Join(workDir, "Dangerfile")+`"]`)
+}
+
+func TestDangerStep_Run_BaseDangerfile(t *testing.T) {
+	cfg := config.Config{
+		RepositoryURL:            repositoryURL,
+		GithubAPIToken:           "github-token",
+		PullRequestRepositoryURL: forkRepositoryURL,
+		PullRequestDestBranch:    "main",
+		ForkPRPolicy:             pullrequest.ForkPolicyBaseDangerfile,
+	}
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git show FETCH_HEAD:./ci/Dangerfile": {Output: "warn('base, not the github-token')"},
+		"git show FETCH_HEAD:./Gemfile":       {Output: "gem 'danger'"},
+		"git show FETCH_HEAD:./Gemfile.lock":  {Output: gemfileLock},
+		"gem list":                            {Output: "bundler (2.2.24)"},
+	})
+
+	cfg.AdditionalOptions = "--dangerfile=ci/Dangerfile"
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir).Run())
+
+	// The fork's Gemfile is evaluated by Bundler, so it is replaced too.
+	baseGemfile := filepath.Join(tmpDir, "base", "Gemfile")
+	require.Equal(t, []string{
+		"danger --version",
+		"git rev-parse --is-shallow-repository",
+		"git fetch origin refs/heads/main",
+		"git cat-file -e FETCH_HEAD:./ci/Dangerfile",
+		"git show FETCH_HEAD:./ci/Dangerfile",
+		"git show FETCH_HEAD:./Gemfile",
+		"git cat-file -e FETCH_HEAD:./Gemfile.lock",
+		"git show FETCH_HEAD:./Gemfile.lock",
+		"ruby -c " + filepath.Join(tmpDir, "base", "Dangerfile"),
+		"gem list",
+		"bundle check --dry-run",
+		"bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"),
+	}, r.Plan())
+	for _, printable := range []string{"bundle check --dry-run", "bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile")} {
+		cmd, err := r.Command(printable)
+		require.NoError(t, err)
+		require.Contains(t, cmd.Env, "BUNDLE_GEMFILE="+baseGemfile, printable)
+	}
+
+	baseDangerfile := filepath.Join(tmpDir, "base", "Dangerfile")
+	content, err := ioutil.ReadFile(baseDangerfile)
+	require.NoError(t, err)
+	// The file contents are not redacted, as that would change the Dangerfile run.
+	require.Equal(t, "warn('base, not the github-token')", string(content))
+
+	wrapper, err := ioutil.ReadFile(filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Contains(t, string(wrapper), `bitrise_dangerfiles = ["`+baseDangerfile+`"]`)
+}
+
+func TestDangerStep_Run_TrustedFiles(t *testing.T) {
+	cfg := config.Config{
+		RepositoryURL:         repositoryURL,
+		GithubAPIToken:        "github-token",
+		PullRequestDestBranch: "main",
+		TrustedDangerfile:     true,
+		TrustedGemfile:        true,
+	}
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"git show FETCH_HEAD:./Dangerfile":   {Output: "warn('base')"},
+		"git show FETCH_HEAD:./Gemfile":      {Output: "gem 'danger'"},
+		"git show FETCH_HEAD:./Gemfile.lock": {Output: gemfileLock},
+		"gem list":                           {Output: "bundler (2.2.24)"},
+		"bundle check --dry-run":             {Err: errors.New("exit status 1")},
+	})
+
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir).Run())
+
+	baseGemfile := filepath.Join(tmpDir, "base", "Gemfile")
+	require.Equal(t, []string{
+		"danger --version",
+		"git rev-parse --is-shallow-repository",
+		"git fetch origin refs/heads/main",
+		"git cat-file -e FETCH_HEAD:./Dangerfile",
+		"git show FETCH_HEAD:./Dangerfile",
+		"git show FETCH_HEAD:./Gemfile",
+		"git cat-file -e FETCH_HEAD:./Gemfile.lock",
+		"git show FETCH_HEAD:./Gemfile.lock",
+		"ruby -c " + filepath.Join(tmpDir, "base", "Dangerfile"),
+		"gem list",
+		"bundle check --dry-run",
+		"bundle install",
+		"bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"),
+	}, r.Plan())
+
+	bundleInstall, err := r.Command("bundle install")
+	require.NoError(t, err)
+	require.Equal(t, []string{"BUNDLE_GEMFILE=" + baseGemfile}, bundleInstall.Env)
+
+	danger, err := r.Command("bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BUNDLE_GEMFILE=" + baseGemfile,
+		"DANGER_GITHUB_API_TOKEN=github-token",
+		"GIT_REPOSITORY_URL=" + repositoryURL,
+	}, danger.Env)
+
+	lock, err := ioutil.ReadFile(baseGemfile + ".lock")
+	require.NoError(t, err)
+	require.Equal(t, gemfileLock, string(lock))
+
+	wrapper, err := ioutil.ReadFile(filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Contains(t, string(wrapper), `bitrise_dangerfiles = ["`+filepath.Join(tmpDir, "base", "Dangerfile")+`"]`)
+}
+
+func TestDangerStep_Run_TrustedFiles_MissingDangerfile(t *testing.T) {
+	scenarios := []struct {
+		name                string
+		cfg                 config.Config
+		expectedPlan        []string
+		expectedDangerfiles []string
+		expectedErr         string
+	}{
+		{
+			name: "trusted Gemfile only",
+			cfg:  config.Config{TrustedGemfile: true},
+			expectedPlan: []string{
+				"danger --version",
+				"git rev-parse --is-shallow-repository",
+				"git fetch origin refs/heads/main",
+				"git show FETCH_HEAD:./Gemfile",
+				"git cat-file -e FETCH_HEAD:./Gemfile.lock",
+				"git show FETCH_HEAD:./Gemfile.lock",
+				"ruby -c WORK/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile",
+			},
+			expectedDangerfiles: []string{"WORK/Dangerfile"},
+		},
+		{
+			name: "trusted Dangerfile with rules",
+			cfg:  config.Config{TrustedDangerfile: true, Rules: "changelog:\n"},
+			expectedPlan: []string{
+				"danger --version",
+				"git rev-parse --is-shallow-repository",
+				"git fetch origin refs/heads/main",
+				"git cat-file -e FETCH_HEAD:./Dangerfile",
+				"ruby -c TMP/rules/Dangerfile",
+				"gem list",
+				"bundle check --dry-run",
+				"bundle exec danger --dangerfile=TMP/Dangerfile",
+			},
+			expectedDangerfiles: []string{"TMP/rules/Dangerfile"},
+		},
+		{
+			name: "trusted Dangerfile without rules",
+			cfg:  config.Config{TrustedDangerfile: true},
+			expectedPlan: []string{
+				"danger --version",
+				"git rev-parse --is-shallow-repository",
+				"git fetch origin refs/heads/main",
+				"git cat-file -e FETCH_HEAD:./Dangerfile",
+			},
+			expectedErr: "Dangerfile not found on the main branch: WORK/Dangerfile",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			workDir, tmpDir := newWorkDir(t), t.TempDir()
+			cfg := scenario.cfg
+			cfg.RepositoryURL = repositoryURL
+			cfg.GithubAPIToken = "github-token"
+			cfg.PullRequestDestBranch = "main"
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{
+				"git cat-file -e FETCH_HEAD:./Dangerfile": {Err: errors.New("exit status 128")},
+				"git show FETCH_HEAD:./Gemfile":           {Output: "gem 'danger'"},
+				"git show FETCH_HEAD:./Gemfile.lock":      {Output: gemfileLock},
+				"gem list":                                {Output: "bundler (2.2.24)"},
+			})
+			step := newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir)
+
+			err := step.Run()
+			var expectedPlan []string
+			for _, cmd := range scenario.expectedPlan {
+				expectedPlan = append(expectedPlan, replaceDirs(cmd, workDir, tmpDir))
+			}
+			require.Equal(t, expectedPlan, r.Plan())
+			if scenario.expectedErr != "" {
+				var failureErr *failure.Error
+				require.True(t, errors.As(err, &failureErr), "%v", err)
+				require.Equal(t, failure.KindDangerfileException, failureErr.Kind)
+				require.EqualError(t, err, replaceDirs(scenario.expectedErr, workDir, tmpDir))
+				return
+			}
+			require.NoError(t, err)
+
+			var expectedDangerfiles []string
+			for _, pth := range scenario.expectedDangerfiles {
+				expectedDangerfiles = append(expectedDangerfiles, replaceDirs(pth, workDir, tmpDir))
+			}
+			require.Equal(t, expectedDangerfiles, step.state.dangerfiles)
+		})
+	}
+}
+
+func TestDangerStep_Run_TrustedFiles_NotPullRequest(t *testing.T) {
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", TrustedDangerfile: true, TrustedGemfile: true}
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir).Run())
+	require.Equal(t, []string{
+		"danger --version",
+		"ruby -c " + filepath.Join(workDir, "Dangerfile"),
+		"gem list",
+		"bundle check --dry-run",
+		"bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"),
+	}, r.Plan())
+}
+
+func TestDangerStep_Run_TokenCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"message":"Bad credentials"}`)
+	}))
+	defer server.Close()
+
+	cfg := config.Config{
+		RepositoryURL:    repositoryURL,
+		GithubAPIToken:   "github-token",
+		GithubHost:       "github.local",
+		GithubAPIBaseURL: server.URL,
+		ValidateTokens:   true,
+	}
+	r := runner.NewFakeRunner(nil)
+	err := newDangerStep(cfg, r, fakeExporter{}, t.TempDir(), t.TempDir()).Run()
+
+	var failureErr *failure.Error
+	require.True(t, errors.As(err, &failureErr), "%v", err)
+	require.Equal(t, failure.KindAuthentication, failureErr.Kind)
+	require.Equal(t, []string{"danger --version"}, r.Plan())
+}
+
+func TestDangerStep_Run_TokenCheck_RestrictedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "token restricted-token", r.Header.Get("Authorization"))
+		w.Header().Set("X-OAuth-Scopes", "read:user")
+		_, _ = fmt.Fprint(w, `{"login":"bitrise-bot"}`)
+	}))
+	defer server.Close()
+
+	cfg := config.Config{
+		RepositoryURL:            repositoryURL,
+		GithubAPIToken:           "github-token",
+		GithubHost:               "github.local",
+		GithubAPIBaseURL:         server.URL,
+		PullRequestRepositoryURL: forkRepositoryURL,
+		ForkPRPolicy:             pullrequest.ForkPolicyRestrictedToken,
+		ForkPRAPIToken:           "restricted-token",
+		ValidateTokens:           true,
+	}
+	tmpDir := t.TempDir()
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"danger --version": {Output: "8.4.0"}})
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, newWorkDir(t), tmpDir).Run())
+
+	danger, err := r.Command("bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Contains(t, danger.Env, "DANGER_GITHUB_API_TOKEN=restricted-token")
+}
+
+func TestDangerStep_Run_GitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/app/installations/678/access_tokens", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{"token":"ghs_installation","expires_at":"2021-08-01T13:00:00Z","permissions":{"pull_requests":"write"}}`)
+	}))
+	defer server.Close()
+
+	cfg := config.Config{
+		RepositoryURL:           repositoryURL,
+		GithubHost:              "github.local",
+		GithubAPIBaseURL:        server.URL,
+		GithubAppID:             "12345",
+		GithubAppInstallationID: "678",
+		GithubAppPrivateKey:     stepconf.Secret(pemKey),
+		ValidateTokens:          true,
+	}
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir).Run())
+
+	danger, err := r.Command("bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"DANGER_GITHUB_API_BASE_URL=" + server.URL,
+		"DANGER_GITHUB_API_TOKEN=ghs_installation",
+		"DANGER_GITHUB_HOST=github.local",
+		"GIT_REPOSITORY_URL=" + repositoryURL,
+	}, danger.Env)
+	require.Contains(t, danger.UnsetEnv, "github_app_private_key")
+}
+
+type mapVariables map[string]string
+
+func (v mapVariables) Get(key string) (string, error) {
+	return v[key], nil
+}
+
+func (v mapVariables) Set(key, value string) error {
+	v[key] = value
+	return nil
+}
+
+func TestDangerStep_Run_CacheDependencies(t *testing.T) {
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", CacheDependencies: true}
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "Gemfile.lock"), []byte(gemfileLock), 0600))
+
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}, "bundle check --dry-run": {Err: errors.New("exit status 1")}})
+	variables := mapVariables{}
+	step := newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir)
+	step.cacheDir = filepath.Join(t.TempDir(), "cache")
+	step.cacheConfig = cache.Config{VariableGetter: variables, VariableSetters: []cache.VariableSetter{variables}}
+
+	require.NoError(t, step.Run())
+
+	bundlePath := filepath.Join(step.cacheDir, "bundle")
+	bundleInstall, err := r.Command("bundle install")
+	require.NoError(t, err)
+	require.Equal(t, []string{"BUNDLE_PATH=" + bundlePath}, bundleInstall.Env)
+
+	danger, err := r.Command("bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"BUNDLE_PATH=" + bundlePath,
+		"DANGER_GITHUB_API_TOKEN=github-token",
+		"GIT_REPOSITORY_URL=" + repositoryURL,
+	}, danger.Env)
+
+	require.Equal(t, bundlePath+" -> "+filepath.Join(step.cacheDir, "bundle.lock.sha256")+"\n", variables[cache.CacheIncludePathsEnvKey])
+}
+
+func TestDangerStep_Run_Timeouts(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		cfg          config.Config
+		results      map[string]runner.FakeResult
+		expectedErr  string
+		expectedPlan []string
+	}{
+		{
+			name: "install phase",
+			cfg:  config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", InstallTimeout: 600},
+			results: map[string]runner.FakeResult{
+				"gem list":               {Output: "bundler (2.2.24)"},
+				"bundle check --dry-run": {Err: errors.New("exit status 1")},
+				"bundle install": {
+					Output: "Fetching gem metadata from https://rubygems.org/\nInstalling nokogiri 1.12.5 with native extensions\n",
+					Err:    &runner.TimeoutError{Command: "bundle install", Timeout: 599 * time.Second},
+				},
+			},
+			expectedErr: "the install phase timed out after 10m0s, killed: bundle install\n" +
+				"Last output lines:\nFetching gem metadata from https://rubygems.org/\nInstalling nokogiri 1.12.5 with native extensions",
+			expectedPlan: []string{"danger --version", "ruby -c WORK/Dangerfile", "gem list", "bundle check --dry-run", "bundle install"},
+		},
+		{
+			name: "danger phase",
+			cfg:  config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", DangerTimeout: 120},
+			results: map[string]runner.FakeResult{
+				"gem list": {Output: "bundler (2.2.24)"},
+				"bundle exec danger --dangerfile=TMP/Dangerfile": {
+					Output: "Octokit::TooManyRequests: GET https://api.github.com/rate_limit: 403 - API rate limit exceeded\n",
+					Err:    &runner.TimeoutError{Command: "bundle exec danger", Timeout: 2 * time.Minute},
+				},
+			},
+			expectedErr: "the danger phase timed out after 2m0s, killed: bundle exec danger\n" +
+				"Last output lines:\nOctokit::TooManyRequests: GET https://api.github.com/rate_limit: 403 - API rate limit exceeded",
+			expectedPlan: []string{"danger --version", "ruby -c WORK/Dangerfile", "gem list", "bundle check --dry-run", "bundle exec danger --dangerfile=TMP/Dangerfile"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			workDir, tmpDir := newWorkDir(t), t.TempDir()
+			results := map[string]runner.FakeResult{}
+			for cmd, result := range scenario.results {
+				results[replaceDirs(cmd, workDir, tmpDir)] = result
+			}
+			r := runner.NewFakeRunner(results)
+			err := newDangerStep(scenario.cfg, r, fakeExporter{}, workDir, tmpDir).Run()
+
+			var failureErr *failure.Error
+			require.True(t, errors.As(err, &failureErr), "%v", err)
+			require.Equal(t, failure.KindTimeout, failureErr.Kind)
+			require.EqualError(t, err, scenario.expectedErr)
+
+			var expectedPlan []string
+			for _, cmd := range scenario.expectedPlan {
+				expectedPlan = append(expectedPlan, replaceDirs(cmd, workDir, tmpDir))
+			}
+			require.Equal(t, expectedPlan, r.Plan())
+		})
+	}
+}
+
+func TestDangerStep_Run_Retries(t *testing.T) {
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", RetryAttempts: 3}
+
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"gem list":               {Output: "bundler (2.2.24)"},
+		"bundle check --dry-run": {Err: errors.New("exit status 1")},
+		"bundle install":         {Output: "Gem::RemoteFetcher::FetchError: bad response Service Unavailable 503", Err: errors.New("exit status 5")},
+	})
+	workDir := newWorkDir(t)
+	err := newDangerStep(cfg, r, fakeExporter{}, workDir, t.TempDir()).Run()
+	var failureErr *failure.Error
+	require.True(t, errors.As(err, &failureErr), "%v", err)
+	require.Equal(t, failure.KindDependencyInstall, failureErr.Kind)
+	rubyCheck := "ruby -c " + filepath.Join(workDir, "Dangerfile")
+	require.Equal(t, []string{"danger --version", rubyCheck, "gem list", "bundle check --dry-run", "bundle install", "bundle install", "bundle install"}, r.Plan())
+
+	tmpDir := t.TempDir()
+	r = runner.NewFakeRunner(map[string]runner.FakeResult{
+		"gem list": {Output: "bundler (2.2.24)"},
+		"bundle exec danger --dangerfile=" + tmpDir + "/Dangerfile": {
+			Output: "Faraday::ConnectionFailed: execution expired\n[!] Invalid `Dangerfile` file: execution expired",
+			Err:    errors.New("exit status 1"),
+		},
+	})
+	err = newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir).Run()
+	require.True(t, errors.As(err, &failureErr), "%v", err)
+	require.Equal(t, []string{"danger --version", rubyCheck, "gem list", "bundle check --dry-run", "bundle exec danger --dangerfile=" + tmpDir + "/Dangerfile"}, r.Plan())
+}
+
+func TestDangerStep_Run_RateLimit(t *testing.T) {
+	scenarios := []struct {
+		name            string
+		remaining       int
+		resetIn         time.Duration
+		expectedOutcome string
+		expectedDanger  bool
+		expectedSleep   bool
+	}{
+		{name: "enough calls", remaining: 500, resetIn: time.Hour, expectedOutcome: "ok", expectedDanger: true},
+		{name: "waits for reset", remaining: 10, resetIn: time.Minute, expectedOutcome: "waited", expectedDanger: true, expectedSleep: true},
+		{name: "resets too late", remaining: 10, resetIn: time.Hour, expectedOutcome: "skipped"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/rate_limit", r.URL.Path)
+				_, _ = fmt.Fprintf(w, `{"resources":{"core":{"limit":5000,"remaining":%d,"reset":%d}}}`,
+					scenario.remaining, time.Now().Add(scenario.resetIn).Unix())
+			}))
+			defer server.Close()
+
+			cfg := config.Config{
+				RepositoryURL:      repositoryURL,
+				GithubAPIToken:     "github-token",
+				GithubHost:         "github.local",
+				GithubAPIBaseURL:   server.URL,
+				RateLimitThreshold: 100,
+				RateLimitMaxWait:   300,
+			}
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+			exporter := fakeExporter{}
+			step := newDangerStep(cfg, r, exporter, newWorkDir(t), t.TempDir())
+			var sleeps []time.Duration
+			step.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			require.NoError(t, step.Run())
+			require.Equal(t, scenario.expectedOutcome, exporter["DANGER_RATE_LIMIT_OUTCOME"])
+			require.Equal(t, scenario.expectedDanger, len(r.Plan()) == 5, "%v", r.Plan())
+			require.Equal(t, scenario.expectedSleep, len(sleeps) == 1, "%v", sleeps)
+		})
+	}
+}
+
+func TestDangerStep_Run_Network(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("X-OAuth-Scopes", "repo")
+		_, _ = fmt.Fprint(w, `{"login":"bitrise-bot"}`)
+	}))
+	defer proxy.Close()
+
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}), 0600))
+
+	cfg := config.Config{
+		RepositoryURL:    repositoryURL,
+		GithubAPIToken:   "github-token",
+		GithubHost:       "github.corp.evilcorp.com",
+		GithubAPIBaseURL: "http://github.corp.evilcorp.com/api/v3",
+		ValidateTokens:   true,
+		ProxyURL:         stepconf.Secret(proxy.URL),
+		NoProxy:          "localhost",
+		CABundlePath:     caBundle,
+	}
+	tmpDir := t.TempDir()
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+	require.NoError(t, newDangerStep(cfg, r, fakeExporter{}, newWorkDir(t), tmpDir).Run())
+
+	trustStore := filepath.Join(tmpDir, "ca-bundle.pem")
+	content, err := ioutil.ReadFile(trustStore)
+	require.NoError(t, err)
+	custom, err := ioutil.ReadFile(caBundle)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(string(content), string(custom)))
+
+	require.Equal(t, []string{"http://github.corp.evilcorp.com/api/v3/user"}, proxied)
+	require.Len(t, r.Commands, 5)
+	for _, cmd := range r.Commands {
+		for _, env := range []string{"HTTPS_PROXY=" + proxy.URL, "NO_PROXY=localhost", "SSL_CERT_FILE=" + trustStore, "GIT_SSL_CAINFO=" + trustStore, "NODE_EXTRA_CA_CERTS=" + caBundle} {
+			require.Contains(t, cmd.Env, env, cmd.String())
+		}
+		require.Contains(t, cmd.UnsetEnv, "proxy_url", cmd.String())
+	}
+}
+
+func TestDangerStep_WriteDiagnostics(t *testing.T) {
+	require.NoError(t, os.Setenv("DIAGNOSTICS_TEST_TOKEN", "env-secret"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("DIAGNOSTICS_TEST_TOKEN"))
+	}()
+
+	workDir, deployDir := newWorkDir(t), t.TempDir()
+	cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", CacheDependencies: true, DeployDir: deployDir}
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{
+		"gem list":                     {Output: "bundler (2.2.24)"},
+		"bundle check --dry-run":       {Err: errors.New("exit status 1")},
+		"bundle install":               {Err: errors.New("exit status 5")},
+		"ruby -v":                      {Output: "ruby 2.7.4p191 (2021-07-07 revision a21a3b7d23) [x86_64-darwin20]\n"},
+		"gem env":                      {Output: "RubyGems Environment:\n  - RUBYGEMS VERSION: 3.1.6\n"},
+		"bundle exec danger --version": {Output: "bundler: command not found: danger", Err: errors.New("exit status 127")},
+	})
+	step := newDangerStep(cfg, r, fakeExporter{}, workDir, t.TempDir())
+	step.cacheDir = t.TempDir()
+	step.cacheConfig = cache.Config{VariableGetter: mapVariables{}, VariableSetters: []cache.VariableSetter{mapVariables{}}}
+
+	err := step.Run()
+	require.Error(t, err)
+	step.writeDiagnostics(err)
+
+	require.Equal(t, []string{"ruby -v", "gem env", "bundle config", "bundle list", "bundle exec danger --version"}, r.Plan()[5:])
+	bundleList, err := r.Command("bundle list")
+	require.NoError(t, err)
+	require.Equal(t, []string{"BUNDLE_PATH=" + filepath.Join(step.cacheDir, "bundle")}, bundleList.Env)
+
+	archive, err := zip.OpenReader(filepath.Join(deployDir, "danger-diagnostics.zip"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, archive.Close())
+	}()
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		contents[f.Name] = string(content)
+	}
+
+	require.Equal(t, "failed to run bundle install, error: exit status 5\n", contents["error.txt"])
+	require.Equal(t, "ruby 2.7.4p191 (2021-07-07 revision a21a3b7d23) [x86_64-darwin20]\n", contents["ruby-version.txt"])
+	require.Equal(t, "bundler: command not found: danger\nbundle exec danger --version failed: exit status 127\n", contents["danger-version.txt"])
+	require.Equal(t, filepath.Join(workDir, "Dangerfile")+"\n", contents["dangerfile.txt"])
+	require.Contains(t, contents["env.txt"], "DIAGNOSTICS_TEST_TOKEN=[REDACTED]\n")
+	require.NotContains(t, contents["env.txt"], "env-secret")
+	require.Len(t, contents, 8)
+}
+
+func TestDangerStep_Run_DryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request in dry run: %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	workDir, tmpDir := newWorkDir(t), t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "Gemfile.lock"), []byte(gemfileLock), 0600))
+
+	cfg := config.Config{
+		RepositoryURL:           repositoryURL,
+		GithubHost:              "github.local",
+		GithubAPIBaseURL:        server.URL,
+		GithubAppID:             "12345",
+		GithubAppInstallationID: "678",
+		GithubAppPrivateKey:     "not parsed in dry run",
+		ValidateTokens:          true,
+		CacheDependencies:       true,
+		RateLimitThreshold:      100,
+		PullRequestDestBranch:   "main",
+		TrustedDangerfile:       true,
+		DryRun:                  true,
+	}
+	r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (1.17.3)"}})
+	variables := mapVariables{}
+	step := newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir)
+	step.cacheDir = t.TempDir()
+	step.cacheConfig = cache.Config{VariableGetter: variables, VariableSetters: []cache.VariableSetter{variables}}
+	require.NoError(t, step.Run())
+
+	require.Equal(t, []string{"danger --version", "gem list"}, r.Plan())
+
+	var notExecuted []string
+	for _, cmd := range step.dryRun.Commands {
+		notExecuted = append(notExecuted, cmd.String())
+	}
+	require.Equal(t, []string{
+		"ruby -c " + filepath.Join(workDir, "Dangerfile"),
+		"gem install bundler --force --no-document --version 2.2.24",
+		"bundle check --dry-run",
+		"bundle install",
+		"bundle exec danger --dangerfile=" + filepath.Join(tmpDir, "Dangerfile"),
+	}, notExecuted)
+
+	danger := step.dryRun.Commands[4]
+	require.Contains(t, danger.Env, "DANGER_GITHUB_API_TOKEN=<GitHub App installation token>")
+	require.Contains(t, danger.Env, "BUNDLE_PATH="+filepath.Join(step.cacheDir, "bundle"))
+	require.Empty(t, variables)
+	require.Equal(t, []string{filepath.Join(workDir, "Dangerfile")}, step.state.dangerfiles)
+}
+
+func TestDangerStep_Run_Rules(t *testing.T) {
+	scenarios := []struct {
+		name                string
+		projectDangerfile   bool
+		expectedDangerfiles []string
+	}{
+		{name: "merged with the project's Dangerfile", projectDangerfile: true, expectedDangerfiles: []string{"WORK/Dangerfile", "TMP/rules/Dangerfile"}},
+		{name: "without a project Dangerfile", expectedDangerfiles: []string{"TMP/rules/Dangerfile"}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			workDir, tmpDir := t.TempDir(), t.TempDir()
+			if scenario.projectDangerfile {
+				workDir = newWorkDir(t)
+			}
+			cfg := config.Config{RepositoryURL: repositoryURL, GithubAPIToken: "github-token", Rules: "changelog:\nfocused_tests:\n"}
+			r := runner.NewFakeRunner(map[string]runner.FakeResult{"gem list": {Output: "bundler (2.2.24)"}})
+			step := newDangerStep(cfg, r, fakeExporter{}, workDir, tmpDir)
+			require.NoError(t, step.Run())
+
+			var expectedDangerfiles, expectedChecks, quoted []string
+			for _, dangerfile := range scenario.expectedDangerfiles {
+				dangerfile = replaceDirs(dangerfile, workDir, tmpDir)
+				expectedDangerfiles = append(expectedDangerfiles, dangerfile)
+				expectedChecks = append(expectedChecks, "ruby -c "+dangerfile)
+				quoted = append(quoted, `"`+dangerfile+`"`)
+			}
+			require.Equal(t, expectedDangerfiles, step.state.dangerfiles)
+			require.Equal(t, append(append([]string{"danger --version"}, expectedChecks...), "gem list", "bundle check --dry-run", "bundle exec danger --dangerfile="+tmpDir+"/Dangerfile"), r.Plan())
+
+			wrapper, err := ioutil.ReadFile(filepath.Join(tmpDir, "Dangerfile"))
+			require.NoError(t, err)
+			require.Contains(t, string(wrapper), "bitrise_dangerfiles = ["+strings.Join(quoted, ", ")+"]")
+
+			compiled, err := ioutil.ReadFile(filepath.Join(tmpDir, "rules", "Dangerfile"))
+			require.NoError(t, err)
+			require.Contains(t, string(compiled), "# changelog\n")
+			require.Contains(t, string(compiled), "# focused_tests\n")
+		})
+	}
+}
+
+func TestDangerStep_Run_InvalidInputs(t *testing.T) {
+	r := runner.NewFakeRunner(nil)
+	err := newDangerStep(config.Config{RepositoryURL: repositoryURL}, r, fakeExporter{}, t.TempDir(), t.TempDir()).Run()
+	require.EqualError(t, err, "issue with input: none of the API tokens have been set. If you want to use GitHub you need to set github_api_token or the GitHub App inputs. If you want to use GitLab you need to set gitlab_api_token")
+	require.Equal(t, []string{"danger --version"}, r.Plan())
+}
+
+// newWorkDir returns a project directory with a Dangerfile.
+func newWorkDir(t *testing.T) string {
+	workDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(workDir, "Dangerfile"), []byte(`message("Hi")`), 0600))
+	return workDir
+}
+
+// replaceDirs replaces the WORK and TMP placeholders of the printable command with the given directories.
+func replaceDirs(cmd, workDir, tmpDir string) string {
+	return strings.Replace(strings.Replace(cmd, "WORK", workDir, 1), "TMP", tmpDir, 1)
+}
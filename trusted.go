@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-danger/basebranch"
+	"github.com/bitrise-steplib/steps-danger/config"
+	"github.com/bitrise-steplib/steps-danger/failure"
+)
+
+// trustedFiles are the copies of the files Danger is run with, taken from the pull request's target branch.
+// dangerfile and gemfile are empty, if the ones of the checked out pull request are used.
+// The dangerfile path does not exist, if the target branch has no Dangerfile and only the rules are run.
+type trustedFiles struct {
+	dangerfile string
+	gemfile    string
+}
+
+// fetchTrustedFiles copies the Dangerfile if trusted_dangerfile is set, and the Gemfile with its lockfile
+// if trusted_gemfile is set, from the pull request's target branch into tmpDir.
+func (s dangerStep) fetchTrustedFiles(cfg config.Config, dangerfile string) (trustedFiles, error) {
+	branch := cfg.PullRequestDestBranch
+	log.Infof("Reading the trusted files from the %s branch", branch)
+
+	baseDir := filepath.Join(s.tmpDir, "base")
+	reader := basebranch.New(s.runner, s.workDir).WithRawRunner(s.rawRunner)
+	if err := reader.Fetch(branch); err != nil {
+		return trustedFiles{}, err
+	}
+
+	var files trustedFiles
+	if cfg.TrustedDangerfile {
+		files.dangerfile = filepath.Join(baseDir, filepath.Base(dangerfile))
+		if reader.Exists(dangerfile) {
+			if err := reader.CopyFile(dangerfile, files.dangerfile); err != nil {
+				return trustedFiles{}, err
+			}
+		} else if cfg.Rules != "" {
+			// The pull request's Dangerfile is not a fallback, it can not be trusted.
+			log.Warnf("Dangerfile does not exist on the %s branch, running the rules only", branch)
+		} else {
+			return trustedFiles{}, failure.Errorf(failure.KindDangerfileException, "Dangerfile not found on the %s branch: %s", branch, dangerfile)
+		}
+	}
+
+	if cfg.TrustedGemfile {
+		gemfile := filepath.Join(s.workDir, "Gemfile")
+		files.gemfile = filepath.Join(baseDir, "Gemfile")
+		if err := reader.CopyFile(gemfile, files.gemfile); err != nil {
+			return trustedFiles{}, err
+		}
+
+		if reader.Exists(gemfile + ".lock") {
+			if err := reader.CopyFile(gemfile+".lock", files.gemfile+".lock"); err != nil {
+				return trustedFiles{}, err
+			}
+		} else {
+			log.Warnf("Gemfile.lock does not exist on the %s branch, the gem versions are not locked", branch)
+		}
+	}
+
+	if files.dangerfile != "" {
+		log.Donef("Using %s", files.dangerfile)
+	}
+	if files.gemfile != "" {
+		log.Donef("Using %s", files.gemfile)
+	}
+	return files, nil
+}